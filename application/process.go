@@ -0,0 +1,287 @@
+package application
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+// clockTicks is the number of clock ticks per second the CPU times of /proc are counted in,
+// which is 100 on every Linux platform Go supports.
+const clockTicks = 100
+
+// cgroupV1Unlimited is the threshold above which cgroup v1 memory limits mean no limit,
+// since they are set to the largest multiple of the page size instead.
+const cgroupV1Unlimited = 1 << 62
+
+// ProcessMetricsService reports metrics of the process and of its cgroup at a regular interval.
+type ProcessMetricsService interface {
+	Close()
+}
+
+// ProcessMetricsOption configures a ProcessMetricsService.
+type ProcessMetricsOption func(*processCollector)
+
+// ProcessMetricsInterval sets how often the process metrics are reported, every minute by default,
+// which is also used when interval is not positive.
+func ProcessMetricsInterval(interval time.Duration) ProcessMetricsOption {
+	return func(c *processCollector) {
+		c.interval = interval
+	}
+}
+
+// ProcessMetricsPrefix sets the prefix of the names of the process metrics, "process" by default.
+func ProcessMetricsPrefix(prefix string) ProcessMetricsOption {
+	return func(c *processCollector) {
+		c.prefix = prefix
+	}
+}
+
+// ProcessMetricsProcDir sets the directory the process metrics are read from, /proc/self by default.
+func ProcessMetricsProcDir(dir string) ProcessMetricsOption {
+	return func(c *processCollector) {
+		c.procDir = dir
+	}
+}
+
+// ProcessMetricsCgroupDir sets the directory the cgroup metrics are read from, /sys/fs/cgroup by default,
+// which is the cgroup of the container in containers with their own cgroup namespace.
+func ProcessMetricsCgroupDir(dir string) ProcessMetricsOption {
+	return func(c *processCollector) {
+		c.cgroupDir = dir
+	}
+}
+
+type processCollector struct {
+	sender    senders.Sender
+	tags      map[string]string
+	source    string
+	prefix    string
+	interval  time.Duration
+	procDir   string
+	cgroupDir string
+
+	ticker    *time.Ticker
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// StartProcessMetricsService will create and start a new ProcessMetricsService, reporting the CPU time, resident
+// memory, threads, open file descriptors and I/O bytes of the process, and the CPU throttling and memory limit and
+// usage of its cgroup, v1 or v2, as points tagged with the application tags. The metrics that cannot be read,
+// such as all of them outside of Linux, are not reported.
+func StartProcessMetricsService(sender senders.Sender, application Tags, source string, setters ...ProcessMetricsOption) ProcessMetricsService {
+	c := &processCollector{
+		sender:    sender,
+		tags:      application.Map(),
+		source:    source,
+		prefix:    "process",
+		interval:  time.Minute,
+		procDir:   "/proc/self",
+		cgroupDir: "/sys/fs/cgroup",
+		stop:      make(chan struct{}),
+	}
+	for _, setter := range setters {
+		setter(c)
+	}
+	if c.interval <= 0 {
+		c.interval = time.Minute
+	}
+
+	c.ticker = time.NewTicker(c.interval)
+	go func() {
+		for {
+			select {
+			case <-c.ticker.C:
+				c.report()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+
+	c.report()
+	return c
+}
+
+// Close stops reporting the process metrics. Closing the service again does nothing.
+func (c *processCollector) Close() {
+	c.closeOnce.Do(func() {
+		c.ticker.Stop()
+		c.stop <- struct{}{} // block until goroutine exits
+	})
+}
+
+func (c *processCollector) report() {
+	values := make(map[string]float64)
+	c.readProc(values)
+	c.readCgroup(values)
+	if len(values) == 0 {
+		return
+	}
+
+	points := make([]senders.Point, 0, len(values))
+	for name, value := range values {
+		points = append(points, senders.Point{
+			Name:   c.prefix + "." + name,
+			Value:  value,
+			Source: c.source,
+			Tags:   c.tags,
+		})
+	}
+	if err := c.sender.SendPoints(context.Background(), points); err != nil {
+		log.Printf("process metrics error sending points: %v\n", err)
+	}
+}
+
+// readProc adds the metrics of the process to values.
+func (c *processCollector) readProc(values map[string]float64) {
+	if stat, ok := c.readFile(c.procDir, "stat"); ok {
+		// the command name may contain spaces, and the fields after it are counted from the state, the 3rd.
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) > 12 {
+			c.add(values, "cpu.user.seconds", fields[11], 1.0/clockTicks)
+			c.add(values, "cpu.system.seconds", fields[12], 1.0/clockTicks)
+		}
+	}
+	if status, ok := c.readKeyValues(c.procDir, "status", ":"); ok {
+		c.add(values, "memory.rss.bytes", strings.TrimSuffix(status["VmRSS"], " kB"), 1024)
+		c.add(values, "threads", status["Threads"], 1)
+	}
+	if io, ok := c.readKeyValues(c.procDir, "io", ":"); ok {
+		c.add(values, "io.read.bytes", io["read_bytes"], 1)
+		c.add(values, "io.write.bytes", io["write_bytes"], 1)
+	}
+	if fds, err := os.ReadDir(filepath.Join(c.procDir, "fd")); err == nil {
+		values["fds"] = float64(len(fds))
+	} else if !os.IsNotExist(err) {
+		log.Printf("process metrics error reading open file descriptors: %v\n", err)
+	}
+}
+
+// readCgroup adds the metrics of the cgroup, v2 if the cgroup directory is a cgroup v2 hierarchy, to values.
+func (c *processCollector) readCgroup(values map[string]float64) {
+	if _, err := os.Stat(filepath.Join(c.cgroupDir, "cgroup.controllers")); err == nil {
+		c.readCgroupV2(values)
+	} else {
+		c.readCgroupV1(values)
+	}
+}
+
+func (c *processCollector) readCgroupV2(values map[string]float64) {
+	if stat, ok := c.readKeyValues(c.cgroupDir, "cpu.stat", " "); ok {
+		c.add(values, "cgroup.cpu.periods", stat["nr_periods"], 1)
+		c.add(values, "cgroup.cpu.throttled.periods", stat["nr_throttled"], 1)
+		c.add(values, "cgroup.cpu.throttled.seconds", stat["throttled_usec"], 1e-6)
+	}
+	if cpuMax, ok := c.readFile(c.cgroupDir, "cpu.max"); ok {
+		if fields := strings.Fields(cpuMax); len(fields) == 2 {
+			c.addRatio(values, "cgroup.cpu.limit.cores", fields[0], fields[1])
+		}
+	}
+	if memoryMax, ok := c.readFile(c.cgroupDir, "memory.max"); ok {
+		c.add(values, "cgroup.memory.limit.bytes", memoryMax, 1)
+	}
+	if current, ok := c.readFile(c.cgroupDir, "memory.current"); ok {
+		c.add(values, "cgroup.memory.usage.bytes", current, 1)
+	}
+}
+
+func (c *processCollector) readCgroupV1(values map[string]float64) {
+	cpuDir := filepath.Join(c.cgroupDir, "cpu")
+	if stat, ok := c.readKeyValues(cpuDir, "cpu.stat", " "); ok {
+		c.add(values, "cgroup.cpu.periods", stat["nr_periods"], 1)
+		c.add(values, "cgroup.cpu.throttled.periods", stat["nr_throttled"], 1)
+		c.add(values, "cgroup.cpu.throttled.seconds", stat["throttled_time"], 1e-9)
+	}
+	if quota, ok := c.readFile(cpuDir, "cpu.cfs_quota_us"); ok && quota != "-1" {
+		if period, ok := c.readFile(cpuDir, "cpu.cfs_period_us"); ok {
+			c.addRatio(values, "cgroup.cpu.limit.cores", quota, period)
+		}
+	}
+	memoryDir := filepath.Join(c.cgroupDir, "memory")
+	if limit, ok := c.readFile(memoryDir, "memory.limit_in_bytes"); ok {
+		if v, err := strconv.ParseFloat(limit, 64); err == nil && v < cgroupV1Unlimited {
+			values["cgroup.memory.limit.bytes"] = v
+		}
+	}
+	if usage, ok := c.readFile(memoryDir, "memory.usage_in_bytes"); ok {
+		c.add(values, "cgroup.memory.usage.bytes", usage, 1)
+	}
+}
+
+// add adds the value of the metric called name to values, parsed from raw and multiplied by scale,
+// unless raw is empty or "max", which cgroup v2 uses for no limit.
+func (c *processCollector) add(values map[string]float64, name, raw string, scale float64) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "max" {
+		return
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("process metrics error parsing %s: %v\n", name, err)
+		return
+	}
+	values[name] = v * scale
+}
+
+// addRatio adds the value of the metric called name to values, the ratio of numerator to denominator.
+func (c *processCollector) addRatio(values map[string]float64, name, numerator, denominator string) {
+	if numerator == "max" {
+		return
+	}
+	n, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		log.Printf("process metrics error parsing %s: %v\n", name, err)
+		return
+	}
+	d, err := strconv.ParseFloat(denominator, 64)
+	if err != nil || d == 0 {
+		log.Printf("process metrics error parsing %s: invalid period '%s'\n", name, denominator)
+		return
+	}
+	values[name] = n / d
+}
+
+// readFile returns the content of the file called name in dir, without surrounding spaces.
+// Missing files are not logged, since most are only found on Linux, or in either cgroup version.
+func (c *processCollector) readFile(dir, name string) (string, bool) {
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("process metrics error reading %s: %v\n", name, err)
+		}
+		return "", false
+	}
+	return strings.TrimSpace(string(content)), true
+}
+
+// readKeyValues returns the values of the file called name in dir, with a key and its value
+// separated by sep on each line.
+func (c *processCollector) readKeyValues(dir, name, sep string) (map[string]string, bool) {
+	content, ok := c.readFile(dir, name)
+	if !ok {
+		return nil, false
+	}
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), sep)
+		if found {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("process metrics error reading %s: %v\n", name, err)
+		return nil, false
+	}
+	return values, true
+}
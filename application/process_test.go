@@ -0,0 +1,134 @@
+package application_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/application"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+type pointsSender struct {
+	senders.Sender
+	mtx    sync.Mutex
+	points map[string]senders.Point
+}
+
+func (s *pointsSender) SendPoints(_ context.Context, points []senders.Point) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, point := range points {
+		s.points[point.Name] = point
+	}
+	return nil
+}
+
+func (s *pointsSender) values() map[string]float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	values := make(map[string]float64)
+	for name, point := range s.points {
+		values[name] = point.Value
+	}
+	return values
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func collectProcessMetrics(t *testing.T, files map[string]string) map[string]float64 {
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	sender := &pointsSender{points: make(map[string]senders.Point)}
+	service := application.StartProcessMetricsService(sender, application.New("app", "srv"), "host",
+		application.ProcessMetricsPrefix("app.process"),
+		application.ProcessMetricsProcDir(filepath.Join(dir, "proc")),
+		application.ProcessMetricsCgroupDir(filepath.Join(dir, "cgroup")))
+	service.Close()
+
+	for _, point := range sender.points {
+		assert.Equal(t, "host", point.Source)
+		assert.Equal(t, "app", point.Tags["application"])
+	}
+	return sender.values()
+}
+
+func TestProcessMetricsService_CgroupV2(t *testing.T) {
+	values := collectProcessMetrics(t, map[string]string{
+		"proc/stat":   "42 (my app) S 1 42 42 0 -1 4194560 1000 0 0 0 250 75 0 0 20 0 12 0 100 1000000 500\n",
+		"proc/status": "Name:\tmy app\nVmRSS:\t    2048 kB\nThreads:\t12\n",
+		"proc/io":     "rchar: 100\nwchar: 200\nread_bytes: 4096\nwrite_bytes: 8192\n",
+		"proc/fd/0":   "",
+		"proc/fd/1":   "",
+		"proc/fd/2":   "",
+
+		"cgroup/cgroup.controllers": "cpu memory\n",
+		"cgroup/cpu.stat":           "usage_usec 1000\nnr_periods 50\nnr_throttled 5\nthrottled_usec 2500000\n",
+		"cgroup/cpu.max":            "150000 100000\n",
+		"cgroup/memory.max":         "max\n",
+		"cgroup/memory.current":     "1048576\n",
+	})
+	assert.Equal(t, map[string]float64{
+		"app.process.cpu.user.seconds":             2.5,
+		"app.process.cpu.system.seconds":           0.75,
+		"app.process.memory.rss.bytes":             2097152,
+		"app.process.threads":                      12,
+		"app.process.io.read.bytes":                4096,
+		"app.process.io.write.bytes":               8192,
+		"app.process.fds":                          3,
+		"app.process.cgroup.cpu.periods":           50,
+		"app.process.cgroup.cpu.throttled.periods": 5,
+		"app.process.cgroup.cpu.throttled.seconds": 2.5,
+		"app.process.cgroup.cpu.limit.cores":       1.5,
+		"app.process.cgroup.memory.usage.bytes":    1048576,
+	}, values)
+}
+
+func TestProcessMetricsService_CgroupV1(t *testing.T) {
+	values := collectProcessMetrics(t, map[string]string{
+		"cgroup/cpu/cpu.stat":                 "nr_periods 10\nnr_throttled 1\nthrottled_time 500000000\n",
+		"cgroup/cpu/cpu.cfs_quota_us":         "-1\n",
+		"cgroup/cpu/cpu.cfs_period_us":        "100000\n",
+		"cgroup/memory/memory.limit_in_bytes": "536870912\n",
+		"cgroup/memory/memory.usage_in_bytes": "1048576\n",
+	})
+	assert.Equal(t, map[string]float64{
+		"app.process.cgroup.cpu.periods":           10,
+		"app.process.cgroup.cpu.throttled.periods": 1,
+		"app.process.cgroup.cpu.throttled.seconds": 0.5,
+		"app.process.cgroup.memory.limit.bytes":    536870912,
+		"app.process.cgroup.memory.usage.bytes":    1048576,
+	}, values)
+}
+
+func TestProcessMetricsService_MissingFiles(t *testing.T) {
+	assert.Empty(t, collectProcessMetrics(t, nil), "nothing is sent without metrics")
+}
+
+func TestProcessMetricsService_CloseTwice(t *testing.T) {
+	sender := &pointsSender{points: make(map[string]senders.Point)}
+	service := application.StartProcessMetricsService(sender, application.New("app", "srv"), "host",
+		application.ProcessMetricsProcDir(t.TempDir()),
+		application.ProcessMetricsCgroupDir(t.TempDir()))
+	service.Close()
+	service.Close()
+}
+
+func TestProcessMetricsService_InvalidInterval(t *testing.T) {
+	sender := &pointsSender{points: make(map[string]senders.Point)}
+	service := application.StartProcessMetricsService(sender, application.New("app", "srv"), "host",
+		application.ProcessMetricsInterval(0),
+		application.ProcessMetricsProcDir(t.TempDir()),
+		application.ProcessMetricsCgroupDir(t.TempDir()))
+	service.Close()
+}
@@ -0,0 +1,203 @@
+package application
+
+import (
+	"log"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+// RuntimeMetricsService reports metrics of the Go runtime at a regular interval.
+type RuntimeMetricsService interface {
+	Close()
+}
+
+// RuntimeMetricsOption configures a RuntimeMetricsService.
+type RuntimeMetricsOption func(*runtimeCollector)
+
+// RuntimeMetricsInterval sets how often the runtime metrics are reported, every minute by default,
+// which is also used when interval is not positive.
+func RuntimeMetricsInterval(interval time.Duration) RuntimeMetricsOption {
+	return func(c *runtimeCollector) {
+		c.interval = interval
+	}
+}
+
+// RuntimeMetricsPrefix sets the prefix of the names of the runtime metrics, "go.runtime" by default.
+func RuntimeMetricsPrefix(prefix string) RuntimeMetricsOption {
+	return func(c *runtimeCollector) {
+		c.prefix = prefix
+	}
+}
+
+// runtimeMetric is a metric of the runtime/metrics package reported as name. The first
+// supported of the names the metric had in the releases of Go is read.
+type runtimeMetric struct {
+	name     string
+	runtime  []string
+	toMillis bool
+}
+
+// runtimeMetrics are the reported runtime metrics. Cumulative metrics, such as the number of GC
+// cycles, are reported as the total since the process started. Histograms are reported as the
+// distributions of the samples since they were last reported.
+var runtimeMetrics = []runtimeMetric{
+	{name: "goroutines", runtime: []string{"/sched/goroutines:goroutines"}},
+	{name: "heap.objects.bytes", runtime: []string{"/memory/classes/heap/objects:bytes"}},
+	{name: "heap.goal.bytes", runtime: []string{"/gc/heap/goal:bytes"}},
+	{name: "heap.allocs.bytes", runtime: []string{"/gc/heap/allocs:bytes"}},
+	{name: "memory.total.bytes", runtime: []string{"/memory/classes/total:bytes"}},
+	{name: "gc.cycles", runtime: []string{"/gc/cycles/total:gc-cycles"}},
+	{name: "cgo.calls", runtime: []string{"/cgo/go-to-c-calls:calls"}},
+	{name: "gc.pauses.millis", runtime: []string{"/sched/pauses/total/gc:seconds", "/gc/pauses:seconds"}, toMillis: true},
+	{name: "sched.latencies.millis", runtime: []string{"/sched/latencies:seconds"}, toMillis: true},
+}
+
+type runtimeCollector struct {
+	sender   senders.Sender
+	tags     map[string]string
+	source   string
+	prefix   string
+	interval time.Duration
+
+	names    []string
+	scales   []float64
+	samples  []metrics.Sample
+	previous map[string][]uint64
+
+	ticker    *time.Ticker
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// StartRuntimeMetricsService will create and start a new RuntimeMetricsService, reporting the goroutines,
+// heap sizes, GC cycles and cgo calls as metrics, and the GC pauses and scheduler latencies as distributions
+// in milliseconds, tagged with the application tags.
+func StartRuntimeMetricsService(sender senders.Sender, application Tags, source string, setters ...RuntimeMetricsOption) RuntimeMetricsService {
+	c := &runtimeCollector{
+		sender:   sender,
+		tags:     application.Map(),
+		source:   source,
+		prefix:   "go.runtime",
+		interval: time.Minute,
+		previous: make(map[string][]uint64),
+		stop:     make(chan struct{}),
+	}
+	for _, setter := range setters {
+		setter(c)
+	}
+	if c.interval <= 0 {
+		c.interval = time.Minute
+	}
+
+	supported := make(map[string]bool)
+	for _, description := range metrics.All() {
+		supported[description.Name] = true
+	}
+	for _, m := range runtimeMetrics {
+		for _, name := range m.runtime {
+			if !supported[name] {
+				continue
+			}
+			scale := 1.0
+			if m.toMillis {
+				scale = 1000
+			}
+			c.names = append(c.names, c.prefix+"."+m.name)
+			c.scales = append(c.scales, scale)
+			c.samples = append(c.samples, metrics.Sample{Name: name})
+			break
+		}
+	}
+
+	// the samples of the histograms before the service started are not reported.
+	c.read()
+	c.ticker = time.NewTicker(c.interval)
+	go func() {
+		for {
+			select {
+			case <-c.ticker.C:
+				c.report()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// Close stops reporting the runtime metrics. Closing the service again does nothing.
+func (c *runtimeCollector) Close() {
+	c.closeOnce.Do(func() {
+		c.ticker.Stop()
+		c.stop <- struct{}{} // block until goroutine exits
+	})
+}
+
+// read reads the runtime metrics, and returns the centroids of the samples of each histogram
+// since it was last read.
+func (c *runtimeCollector) read() map[string][]histogram.Centroid {
+	metrics.Read(c.samples)
+	centroids := make(map[string][]histogram.Centroid)
+	for i, sample := range c.samples {
+		if sample.Value.Kind() != metrics.KindFloat64Histogram {
+			continue
+		}
+		h := sample.Value.Float64Histogram()
+		centroids[sample.Name] = histogramCentroids(h, c.previous[sample.Name], c.scales[i])
+		// the histogram may be reused by the next read.
+		c.previous[sample.Name] = append(c.previous[sample.Name][:0], h.Counts...)
+	}
+	return centroids
+}
+
+func (c *runtimeCollector) report() {
+	centroids := c.read()
+	ts := time.Now().Unix()
+	for i, sample := range c.samples {
+		var err error
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			err = c.sender.SendMetric(c.names[i], float64(sample.Value.Uint64()), ts, c.source, c.tags)
+		case metrics.KindFloat64:
+			err = c.sender.SendMetric(c.names[i], sample.Value.Float64(), ts, c.source, c.tags)
+		case metrics.KindFloat64Histogram:
+			if len(centroids[sample.Name]) == 0 {
+				continue
+			}
+			err = c.sender.SendDistribution(c.names[i], centroids[sample.Name],
+				map[histogram.Granularity]bool{histogram.MINUTE: true}, ts, c.source, c.tags)
+		}
+		if err != nil {
+			log.Printf("runtime metrics error sending %s: %v\n", c.names[i], err)
+		}
+	}
+}
+
+// histogramCentroids returns a centroid for each bucket of h with samples since the counts
+// in previous, at the middle of the bucket multiplied by scale. The buckets open on one side
+// have their centroid at their finite bound.
+func histogramCentroids(h *metrics.Float64Histogram, previous []uint64, scale float64) []histogram.Centroid {
+	var centroids []histogram.Centroid
+	for i, count := range h.Counts {
+		if i < len(previous) {
+			count -= previous[i]
+		}
+		if count == 0 {
+			continue
+		}
+		lower, upper := h.Buckets[i], h.Buckets[i+1]
+		value := (lower + upper) / 2
+		if math.IsInf(lower, -1) {
+			value = upper
+		} else if math.IsInf(upper, 1) {
+			value = lower
+		}
+		centroids = append(centroids, histogram.Centroid{Value: value * scale, Count: int(count)})
+	}
+	return centroids
+}
@@ -0,0 +1,80 @@
+package application_test
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wavefronthq/wavefront-sdk-go/application"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+type runtimeSender struct {
+	senders.Sender
+	mtx           sync.Mutex
+	metrics       map[string]map[string]string
+	distributions map[string][]histogram.Centroid
+}
+
+func (s *runtimeSender) SendMetric(name string, _ float64, _ int64, _ string, tags map[string]string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.metrics[name] = tags
+	return nil
+}
+
+func (s *runtimeSender) SendDistribution(name string, centroids []histogram.Centroid, _ map[histogram.Granularity]bool, _ int64, _ string, _ map[string]string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.distributions[name] = append(s.distributions[name], centroids...)
+	return nil
+}
+
+func TestRuntimeMetricsService(t *testing.T) {
+	sender := &runtimeSender{
+		metrics:       make(map[string]map[string]string),
+		distributions: make(map[string][]histogram.Centroid),
+	}
+	service := application.StartRuntimeMetricsService(sender, application.New("app", "srv"), "host",
+		application.RuntimeMetricsInterval(10*time.Millisecond))
+	defer service.Close()
+
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		sender.mtx.Lock()
+		defer sender.mtx.Unlock()
+		return len(sender.distributions["go.runtime.gc.pauses.millis"]) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	sender.mtx.Lock()
+	defer sender.mtx.Unlock()
+	assert.Contains(t, sender.metrics, "go.runtime.goroutines")
+	assert.Contains(t, sender.metrics, "go.runtime.heap.objects.bytes")
+	assert.Equal(t, "app", sender.metrics["go.runtime.gc.cycles"]["application"])
+	for _, centroid := range sender.distributions["go.runtime.gc.pauses.millis"] {
+		assert.Positive(t, centroid.Count)
+	}
+}
+
+func TestRuntimeMetricsService_CloseTwice(t *testing.T) {
+	sender := &runtimeSender{
+		metrics:       make(map[string]map[string]string),
+		distributions: make(map[string][]histogram.Centroid),
+	}
+	service := application.StartRuntimeMetricsService(sender, application.New("app", "srv"), "host")
+	service.Close()
+	service.Close()
+}
+
+func TestRuntimeMetricsService_InvalidInterval(t *testing.T) {
+	sender := &runtimeSender{
+		metrics:       make(map[string]map[string]string),
+		distributions: make(map[string][]histogram.Centroid),
+	}
+	service := application.StartRuntimeMetricsService(sender, application.New("app", "srv"), "host",
+		application.RuntimeMetricsInterval(0))
+	service.Close()
+}
@@ -254,6 +254,20 @@ func (h *histogramImpl) distributions(clean bool) []Distribution {
 	return distributions
 }
 
+// drain returns all samples, including the ones of the current time slice, and clears the histogram.
+func (h *histogramImpl) drain() []Distribution {
+	h.rotateCurrentTDigestIfNeedIt()
+
+	h.mutex.Lock()
+	if h.currentTimedBin.tdigest.Count() > 0 {
+		h.priorTimedBinsList = append(h.priorTimedBinsList, h.currentTimedBin)
+		h.currentTimedBin = h.newTimedBin()
+	}
+	h.mutex.Unlock()
+
+	return h.distributions(true)
+}
+
 func (h *histogramImpl) rotateCurrentTDigestIfNeedIt() {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
@@ -0,0 +1,186 @@
+package histogram
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal/key"
+)
+
+// Sender sends distributions to Wavefront, as senders.Sender does.
+type Sender interface {
+	SendDistribution(name string, centroids []Centroid, hgs map[Granularity]bool, ts int64, source string, tags map[string]string) error
+}
+
+// Reporter owns histograms identified by their name and tags, and periodically sends the
+// distributions of their completed time slices.
+type Reporter struct {
+	sender   Sender
+	interval time.Duration
+	source   string
+
+	mtx        sync.Mutex
+	histograms map[string]*reportedHistogram
+
+	ticker *time.Ticker
+	stop   chan struct{}
+}
+
+type reportedHistogram struct {
+	Histogram
+	name string
+	tags map[string]string
+}
+
+// ReporterOption configures a Reporter.
+type ReporterOption func(*Reporter)
+
+// ReportInterval sets how often a Reporter sends distributions, every minute by default,
+// which is also used when interval is not positive.
+// Distributions are only complete once the time slice of the granularity of their histogram
+// is over, so reporting more often than every minute only makes them reported sooner.
+func ReportInterval(interval time.Duration) ReporterOption {
+	return func(r *Reporter) {
+		r.interval = interval
+	}
+}
+
+// ReportSource sets the source of the distributions, the default source of the Sender by default.
+func ReportSource(source string) ReporterOption {
+	return func(r *Reporter) {
+		r.source = source
+	}
+}
+
+// NewReporter creates a Reporter sending distributions with sender once started.
+func NewReporter(sender Sender, setters ...ReporterOption) *Reporter {
+	r := &Reporter{
+		sender:     sender,
+		interval:   time.Minute,
+		histograms: make(map[string]*reportedHistogram),
+	}
+	for _, setter := range setters {
+		setter(r)
+	}
+	if r.interval <= 0 {
+		r.interval = time.Minute
+	}
+	return r
+}
+
+// Start sends the distributions at every interval, until Close is called.
+func (r *Reporter) Start() {
+	if r.ticker != nil {
+		return
+	}
+	r.ticker = time.NewTicker(r.interval)
+	r.stop = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-r.ticker.C:
+				if err := r.Report(); err != nil {
+					log.Printf("histogram reporter: error reporting distributions: %v\n", err)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Histogram returns the histogram called name with tags, creating it with setters if needed.
+func (r *Reporter) Histogram(name string, tags map[string]string, setters ...Option) Histogram {
+	k := key.Of(name, tags)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if h, ok := r.histograms[k]; ok {
+		return h.Histogram
+	}
+	h := &reportedHistogram{Histogram: New(setters...), name: name, tags: tags}
+	r.histograms[k] = h
+	return h.Histogram
+}
+
+// Add reports h as the histogram called name with tags, replacing the one reported so far.
+func (r *Reporter) Add(name string, tags map[string]string, h Histogram) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.histograms[key.Of(name, tags)] = &reportedHistogram{Histogram: h, name: name, tags: tags}
+}
+
+// Remove stops reporting the histogram called name with tags, and returns it, or nil
+// if there is none. The distributions it did not report yet are left in it.
+func (r *Reporter) Remove(name string, tags map[string]string) Histogram {
+	k := key.Of(name, tags)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	h, ok := r.histograms[k]
+	if !ok {
+		return nil
+	}
+	delete(r.histograms, k)
+	return h.Histogram
+}
+
+// Report sends the distributions of the completed time slices of the histograms now.
+func (r *Reporter) Report() error {
+	return r.report(Histogram.Distributions)
+}
+
+// Close stops reporting periodically, and sends the distributions of every time slice of the
+// histograms, including the current one. Closing a Reporter again only sends what was added since.
+func (r *Reporter) Close() error {
+	if r.ticker != nil {
+		r.ticker.Stop()
+		r.stop <- struct{}{} // block until goroutine exits
+		r.ticker = nil
+	}
+	return r.report(Drain)
+}
+
+// report sends the distributions drain takes from each histogram. It returns the number of
+// distributions that could not be sent along with the first error.
+func (r *Reporter) report(drain func(Histogram) []Distribution) error {
+	r.mtx.Lock()
+	histograms := make([]*reportedHistogram, 0, len(r.histograms))
+	for _, h := range r.histograms {
+		histograms = append(histograms, h)
+	}
+	r.mtx.Unlock()
+
+	var failed int
+	var firstErr error
+	for _, h := range histograms {
+		granularities := map[Granularity]bool{h.Granularity(): true}
+		for _, distribution := range drain(h.Histogram) {
+			if len(distribution.Centroids) == 0 {
+				continue
+			}
+			err := r.sender.SendDistribution(h.name, distribution.Centroids, granularities,
+				distribution.Timestamp.Unix(), r.source, h.tags)
+			if err != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d distributions failed to be sent, first error: %w", failed, firstErr)
+	}
+	return nil
+}
+
+// Drain returns the distributions of every time slice of h, including the current one,
+// and clears h. It is meant to report a histogram one last time, once it is no longer updated.
+// Histograms not created with New only return the distributions of their completed time slices.
+func Drain(h Histogram) []Distribution {
+	if impl, ok := h.(*histogramImpl); ok {
+		return impl.drain()
+	}
+	return h.Distributions()
+}
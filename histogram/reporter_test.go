@@ -0,0 +1,114 @@
+package histogram
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentDistribution struct {
+	name          string
+	centroids     []Centroid
+	granularities map[Granularity]bool
+	ts            int64
+	source        string
+	tags          map[string]string
+}
+
+type recordingSender struct {
+	mtx           sync.Mutex
+	distributions []sentDistribution
+	err           error
+}
+
+func (s *recordingSender) SendDistribution(name string, centroids []Centroid, hgs map[Granularity]bool, ts int64, source string, tags map[string]string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.distributions = append(s.distributions, sentDistribution{name, centroids, hgs, ts, source, tags})
+	return s.err
+}
+
+func (s *recordingSender) sent() []sentDistribution {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	sent := s.distributions
+	s.distributions = nil
+	return sent
+}
+
+func TestReporter(t *testing.T) {
+	c := &clock{currTime: time.Unix(1700000000, 0)}
+	sender := &recordingSender{}
+	reporter := NewReporter(sender, ReportInterval(time.Hour), ReportSource("host"))
+
+	latency := reporter.Histogram("latency", map[string]string{"path": "/users"}, TimeSupplier(c.Now))
+	assert.Same(t, latency, reporter.Histogram("latency", map[string]string{"path": "/users"}))
+	latency.Update(10)
+	latency.Update(20)
+	daily := New(GranularityOption(DAY), TimeSupplier(c.Now))
+	reporter.Add("requests", nil, daily)
+	daily.Update(1)
+
+	require.NoError(t, reporter.Report())
+	assert.Empty(t, sender.sent(), "distributions are reported once complete")
+
+	c.Add(time.Minute)
+	latency.Update(30)
+	require.NoError(t, reporter.Report())
+	sent := sender.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, sentDistribution{
+		name:          "latency",
+		centroids:     []Centroid{{Value: 10, Count: 1}, {Value: 20, Count: 1}},
+		granularities: map[Granularity]bool{MINUTE: true},
+		ts:            1699999980,
+		source:        "host",
+		tags:          map[string]string{"path": "/users"},
+	}, sent[0])
+
+	require.NoError(t, reporter.Close())
+	sent = sender.sent()
+	require.Len(t, sent, 2, "the current time slices are reported on close")
+	for _, distribution := range sent {
+		switch distribution.name {
+		case "latency":
+			assert.Equal(t, []Centroid{{Value: 30, Count: 1}}, distribution.centroids)
+		case "requests":
+			assert.Equal(t, map[Granularity]bool{DAY: true}, distribution.granularities)
+			assert.Equal(t, []Centroid{{Value: 1, Count: 1}}, distribution.centroids)
+		default:
+			t.Errorf("unexpected distribution %s", distribution.name)
+		}
+	}
+	assert.Empty(t, Drain(latency))
+}
+
+func TestReporter_Errors(t *testing.T) {
+	sender := &recordingSender{err: errors.New("buffer full")}
+	reporter := NewReporter(sender, ReportInterval(10*time.Millisecond))
+	reporter.Start()
+	reporter.Start()
+	h := reporter.Histogram("latency", nil)
+	h.Update(1)
+	assert.Same(t, h, reporter.Remove("latency", nil))
+	assert.Nil(t, reporter.Remove("latency", nil))
+	reporter.Histogram("latency", nil).Update(1)
+	reporter.Histogram("empty", nil)
+
+	err := reporter.Close()
+	assert.ErrorIs(t, err, sender.err)
+	assert.Len(t, sender.sent(), 1, "empty distributions are not sent")
+	assert.NoError(t, reporter.Close())
+	assert.Empty(t, sender.sent())
+}
+
+func TestReporter_InvalidInterval(t *testing.T) {
+	reporter := NewReporter(&recordingSender{}, ReportInterval(0))
+	assert.Equal(t, time.Minute, reporter.interval)
+	reporter.Start()
+	assert.NoError(t, reporter.Close())
+}
@@ -1,7 +1,10 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -14,14 +17,26 @@ type backgroundFlusher struct {
 	ticker   *time.Ticker
 	interval time.Duration
 	handler  LineHandler
-	stop     chan struct{}
+	workers  int
+	ticked   func()
+	cancel   context.CancelFunc
+	done     chan struct{}
 }
 
 func NewBackgroundFlusher(interval time.Duration, handler LineHandler) BackgroundFlusher {
+	return newBackgroundFlusher(interval, handler, 1)
+}
+
+// newBackgroundFlusher returns a flusher that runs workers flushes of handler concurrently
+// on every tick.
+func newBackgroundFlusher(interval time.Duration, handler LineHandler, workers int) *backgroundFlusher {
+	if workers < 1 {
+		workers = 1
+	}
 	return &backgroundFlusher{
 		interval: interval,
 		handler:  handler,
-		stop:     make(chan struct{}),
+		workers:  workers,
 	}
 }
 
@@ -31,25 +46,64 @@ func (f *backgroundFlusher) Start() {
 		return
 	}
 	f.ticker = time.NewTicker(f.interval)
+	ticks := f.ticker.C
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.done = make(chan struct{})
 	go func() {
+		defer close(f.done)
 		for {
 			select {
-			case tick := <-f.ticker.C:
+			case tick := <-ticks:
 				log.Printf("%s -- flushing at: %s\n", format, tick)
-				err := f.handler.FlushWithThrottling()
-				if err != nil {
-					log.Printf("%s -- error during background flush: %s\n", format, err.Error())
-				} else {
-					log.Printf("%s -- flush completed at %s\n", format, time.Now())
-				}
-			case <-f.stop:
+				f.flush(ctx, format)
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 }
 
+// flush runs the flushes of a tick, and waits for all of them to complete.
+func (f *backgroundFlusher) flush(ctx context.Context, format string) {
+	errs := make([]error, f.workers)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = f.handler.FlushWithThrottling(ctx)
+		}(i)
+	}
+	wg.Wait()
+	if f.ticked != nil {
+		f.ticked()
+	}
+
+	failed := false
+	for _, err := range errs {
+		if errors.Is(err, errCircuitOpen) {
+			// the circuit breaker logs when it opens and closes, not on every tick it stays open.
+			failed = true
+			continue
+		}
+		if err != nil {
+			failed = true
+			log.Printf("%s -- error during background flush: %s\n", format, err.Error())
+		}
+	}
+	if !failed {
+		log.Printf("%s -- flush completed at %s\n", format, time.Now())
+	}
+}
+
+// Stop stops the flusher, canceling the flushes in progress and waiting for them to return.
 func (f *backgroundFlusher) Stop() {
+	if f.ticker == nil {
+		return
+	}
 	f.ticker.Stop()
-	f.stop <- struct{}{}
+	f.ticker = nil
+	f.cancel()
+	<-f.done
 }
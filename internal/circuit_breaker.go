@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int32
+
+const (
+	// CircuitClosed lets every report through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every report without attempting it.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe report through to decide whether to close or open again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+var errCircuitOpen = errors.New("error: circuit breaker is open")
+
+// gate is implemented by reporters that can tell a report would be rejected without
+// attempting it, which lets line handlers skip building the request altogether.
+type gate interface {
+	Allows() bool
+}
+
+// CircuitBreaker is a Reporter that stops reporting to an endpoint after
+// failureThreshold consecutive failures. Once open, reports fail fast with an error
+// until openTimeout has elapsed, then a single probe report is let through:
+// the breaker closes again if it succeeds and re-opens if it fails.
+// Transport errors, 429 and 5xx responses count as failures.
+type CircuitBreaker struct {
+	name             string
+	reporter         Reporter
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mtx      sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// NewCircuitBreaker wraps reporter with a CircuitBreaker. name identifies the endpoint in logs.
+func NewCircuitBreaker(name string, reporter Reporter, failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:             name,
+		reporter:         reporter,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// Report forwards to the wrapped Reporter unless the breaker is open.
+func (cb *CircuitBreaker) Report(format string, pointLines string) (*http.Response, error) {
+	return cb.ReportContext(context.Background(), format, pointLines)
+}
+
+// ReportContext forwards to the wrapped Reporter unless the breaker is open.
+// A report abandoned because ctx is done does not count as a failure of the endpoint.
+func (cb *CircuitBreaker) ReportContext(ctx context.Context, format string, pointLines string) (*http.Response, error) {
+	return cb.guard(ctx, func() (*http.Response, error) {
+		return cb.reporter.ReportContext(ctx, format, pointLines)
+	})
+}
+
+// ReportLines is like ReportContext, for a batch of lines that the wrapped Reporter
+// encodes without joining them first when it can.
+func (cb *CircuitBreaker) ReportLines(ctx context.Context, format string, lines []string) (*http.Response, error) {
+	return cb.guard(ctx, func() (*http.Response, error) {
+		return reportLines(ctx, cb.reporter, format, lines)
+	})
+}
+
+func (cb *CircuitBreaker) guard(ctx context.Context, report func() (*http.Response, error)) (*http.Response, error) {
+	if !cb.acquire() {
+		return nil, errCircuitOpen
+	}
+	resp, err := report()
+	if ctx.Err() != nil {
+		cb.abandon()
+	} else {
+		cb.record(isEndpointFailure(resp, err))
+	}
+	return resp, err
+}
+
+// Allows reports whether a report would currently be let through.
+func (cb *CircuitBreaker) Allows() bool {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		return cb.now().Sub(cb.openedAt) >= cb.openTimeout
+	case CircuitHalfOpen:
+		return !cb.probing
+	default:
+		return true
+	}
+}
+
+// State returns the current state of the breaker.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) acquire() bool {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.openTimeout {
+			return false
+		}
+		cb.transition(CircuitHalfOpen)
+		cb.probing = true
+		return true
+	case CircuitHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (cb *CircuitBreaker) record(failed bool) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	if cb.state == CircuitHalfOpen {
+		cb.probing = false
+		if failed {
+			cb.open()
+		} else {
+			cb.failures = 0
+			cb.transition(CircuitClosed)
+		}
+		return
+	}
+	if !failed {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.state == CircuitClosed && cb.failures >= cb.failureThreshold {
+		cb.open()
+	}
+}
+
+// abandon releases the probe of a half-open breaker without deciding on its state.
+func (cb *CircuitBreaker) abandon() {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+	cb.probing = false
+}
+
+func (cb *CircuitBreaker) open() {
+	cb.openedAt = cb.now()
+	cb.transition(CircuitOpen)
+}
+
+func (cb *CircuitBreaker) transition(state CircuitState) {
+	if cb.state != state {
+		log.Printf("circuit breaker for %s: %s -> %s\n", cb.name, cb.state, state)
+		cb.state = state
+	}
+}
+
+func isEndpointFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return shouldRetry(err)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/internal/auth"
+)
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	reporter := &fakeReporter{}
+	reporter.SetHTTPStatus(503)
+	cb := NewCircuitBreaker("test", reporter, 3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, CircuitClosed, cb.State())
+		_, err := cb.Report(metricFormat, "line\n")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, CircuitOpen, cb.State())
+	assert.False(t, cb.Allows())
+
+	_, err := cb.Report(metricFormat, "line\n")
+	assert.Equal(t, errCircuitOpen, err)
+	assert.Equal(t, 3, reporter.ReportCallCount())
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	reporter := &fakeReporter{}
+	cb := NewCircuitBreaker("test", reporter, 2, time.Minute)
+
+	reporter.SetHTTPStatus(500)
+	_, _ = cb.Report(metricFormat, "line\n")
+	reporter.SetHTTPStatus(0)
+	_, _ = cb.Report(metricFormat, "line\n")
+	reporter.SetHTTPStatus(500)
+	_, _ = cb.Report(metricFormat, "line\n")
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitBreaker_IgnoresClientErrors(t *testing.T) {
+	reporter := &fakeReporter{}
+	cb := NewCircuitBreaker("test", reporter, 1, time.Minute)
+
+	reporter.SetHTTPStatus(400)
+	_, _ = cb.Report(metricFormat, "line\n")
+	assert.Equal(t, CircuitClosed, cb.State())
+
+	reporter.SetHTTPStatus(0)
+	reporter.error = auth.NewAuthError(fmt.Errorf("bad credentials"))
+	_, _ = cb.Report(metricFormat, "line\n")
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	now := time.Now()
+	reporter := &fakeReporter{error: fmt.Errorf("connection refused")}
+	cb := NewCircuitBreaker("test", reporter, 1, time.Minute)
+	cb.now = func() time.Time { return now }
+
+	_, _ = cb.Report(metricFormat, "line\n")
+	assert.Equal(t, CircuitOpen, cb.State())
+
+	now = now.Add(time.Minute)
+	assert.True(t, cb.Allows())
+	_, err := cb.Report(metricFormat, "line\n")
+	assert.NotEqual(t, errCircuitOpen, err)
+	assert.Equal(t, CircuitOpen, cb.State(), "failed probe should re-open the breaker")
+
+	now = now.Add(time.Minute)
+	reporter.error = nil
+	require.True(t, cb.acquire())
+	assert.Equal(t, CircuitHalfOpen, cb.State())
+	assert.False(t, cb.Allows(), "only one probe at a time")
+	cb.record(false)
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestFlush_WhenCircuitOpen_KeepsLinesBuffered(t *testing.T) {
+	reporter := &fakeReporter{error: fmt.Errorf("connection refused")}
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	lh.Reporter = NewCircuitBreaker("test", reporter, 1, time.Hour)
+
+	addLines(lh, 20, 20, t)
+	assert.Error(t, lh.Flush())
+	assert.Equal(t, 20, lh.buffer.Len())
+	assert.Equal(t, 1, reporter.ReportCallCount())
+
+	assert.Equal(t, errCircuitOpen, lh.Flush())
+	assert.Equal(t, errCircuitOpen, lh.FlushAll())
+	assert.Equal(t, 20, lh.buffer.Len())
+	assert.Equal(t, 1, reporter.ReportCallCount())
+}
+
+func TestBackgroundFlush_WhenCircuitOpen_LogsStateChangesOnly(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	reporter := &fakeReporter{error: fmt.Errorf("connection refused")}
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	lh.Reporter = NewCircuitBreaker("test", reporter, 1, time.Hour)
+	addLines(lh, 20, 20, t)
+
+	flusher := newBackgroundFlusher(time.Hour, lh, 1)
+	for i := 0; i < 3; i++ {
+		flusher.flush(context.Background(), lh.Format())
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "circuit breaker for test"))
+	assert.NotContains(t, logs.String(), errCircuitOpen.Error())
+	assert.NotContains(t, logs.String(), "flush completed")
+}
+
+func TestCircuitBreaker_CanceledReportIsNotAFailure(t *testing.T) {
+	reporter := &fakeReporter{}
+	cb := NewCircuitBreaker("test", reporter, 1, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cb.ReportContext(ctx, metricFormat, "line\n")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, CircuitClosed, cb.State())
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sync"
+)
+
+// Codec compresses the body of the reports sent over HTTP.
+type Codec interface {
+	// ContentEncoding returns the value of the Content-Encoding header of the reports,
+	// empty when they are not compressed.
+	ContentEncoding() string
+	// NewWriter returns a writer compressing to w. Closing it completes the compressed
+	// stream without closing w.
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+// NoCompression sends reports as they are.
+var NoCompression Codec = identityCodec{}
+
+type identityCodec struct{}
+
+func (identityCodec) ContentEncoding() string {
+	return ""
+}
+
+func (identityCodec) NewWriter(w io.Writer) io.WriteCloser {
+	return nopWriteCloser{w}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// resetWriter is a compressing writer that can be reused, like gzip.Writer and zlib.Writer.
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// pooledCodec reuses its writers, which are expensive to allocate.
+type pooledCodec struct {
+	encoding string
+	writers  sync.Pool
+}
+
+func newPooledCodec(encoding string, newWriter func() (resetWriter, error)) (Codec, error) {
+	// creating a first writer validates the settings of the codec.
+	first, err := newWriter()
+	if err != nil {
+		return nil, err
+	}
+	c := &pooledCodec{encoding: encoding}
+	c.writers.New = func() interface{} {
+		w, _ := newWriter()
+		return w
+	}
+	c.writers.Put(first)
+	return c, nil
+}
+
+// NewGzipCodec returns a Codec compressing reports with gzip at level, between
+// gzip.HuffmanOnly and gzip.BestCompression.
+func NewGzipCodec(level int) (Codec, error) {
+	return newPooledCodec(gzipFormat, func() (resetWriter, error) {
+		return gzip.NewWriterLevel(io.Discard, level)
+	})
+}
+
+// NewDeflateCodec returns a Codec compressing reports with the deflate Content-Encoding,
+// the zlib format, at level, between zlib.HuffmanOnly and zlib.BestCompression.
+func NewDeflateCodec(level int) (Codec, error) {
+	return newPooledCodec(deflateFormat, func() (resetWriter, error) {
+		return zlib.NewWriterLevel(io.Discard, level)
+	})
+}
+
+func (c *pooledCodec) ContentEncoding() string {
+	return c.encoding
+}
+
+func (c *pooledCodec) NewWriter(w io.Writer) io.WriteCloser {
+	zw := c.writers.Get().(resetWriter)
+	zw.Reset(w)
+	return &pooledWriter{resetWriter: zw, codec: c}
+}
+
+// pooledWriter goes back to the pool of its codec once closed.
+type pooledWriter struct {
+	resetWriter
+	codec *pooledCodec
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.resetWriter.Close()
+	w.resetWriter.Reset(io.Discard)
+	w.codec.writers.Put(w.resetWriter)
+	return err
+}
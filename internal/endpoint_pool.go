@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// LoadBalancingPolicy decides which endpoint of an EndpointPool a report is sent to first.
+type LoadBalancingPolicy int
+
+const (
+	// RoundRobin spreads reports evenly over the healthy endpoints.
+	RoundRobin LoadBalancingPolicy = iota
+	// LeastFailures sends reports to the healthy endpoint with the fewest recent failures.
+	LeastFailures
+	// Failover sends reports to the first healthy endpoint, in the order the endpoints were given.
+	Failover
+)
+
+func (p LoadBalancingPolicy) String() string {
+	switch p {
+	case RoundRobin:
+		return "round-robin"
+	case LeastFailures:
+		return "least-failures"
+	case Failover:
+		return "failover"
+	default:
+		return "unknown"
+	}
+}
+
+// EndpointPool is a Reporter sending each report to one of several endpoints, chosen by
+// its LoadBalancingPolicy. A report that fails on an endpoint is attempted on the next one.
+// The health of each endpoint is tracked by its own CircuitBreaker: an endpoint is left
+// out once its breaker opens, and re-admitted once a probe report succeeds.
+type EndpointPool struct {
+	// keep first to guarantee 64-bit alignment on 32-bit machines.
+	next      uint64
+	policy    LoadBalancingPolicy
+	endpoints []*poolEndpoint
+}
+
+type poolEndpoint struct {
+	// failures counts the recent failures of the endpoint, each successful report forgiving one.
+	// keep first to guarantee 64-bit alignment on 32-bit machines.
+	failures int64
+	*CircuitBreaker
+}
+
+// NewEndpointPool returns an EndpointPool over the endpoints guarded by breakers.
+func NewEndpointPool(policy LoadBalancingPolicy, breakers ...*CircuitBreaker) *EndpointPool {
+	pool := &EndpointPool{policy: policy}
+	for _, breaker := range breakers {
+		pool.endpoints = append(pool.endpoints, &poolEndpoint{CircuitBreaker: breaker})
+	}
+	return pool
+}
+
+// Report sends pointLines to an endpoint of the pool.
+func (p *EndpointPool) Report(format string, pointLines string) (*http.Response, error) {
+	return p.ReportContext(context.Background(), format, pointLines)
+}
+
+// ReportContext is like Report, with ctx bounding the reports.
+func (p *EndpointPool) ReportContext(ctx context.Context, format string, pointLines string) (*http.Response, error) {
+	return p.report(ctx, func(reporter Reporter) (*http.Response, error) {
+		return reporter.ReportContext(ctx, format, pointLines)
+	})
+}
+
+// ReportLines is like ReportContext, for a batch of lines that the endpoints encode
+// without joining them first when they can.
+func (p *EndpointPool) ReportLines(ctx context.Context, format string, lines []string) (*http.Response, error) {
+	return p.report(ctx, func(reporter Reporter) (*http.Response, error) {
+		return reportLines(ctx, reporter, format, lines)
+	})
+}
+
+// report attempts send on the endpoints in the order of the policy, until one succeeds.
+// It fails with errCircuitOpen when no endpoint could be attempted.
+func (p *EndpointPool) report(ctx context.Context, send func(Reporter) (*http.Response, error)) (*http.Response, error) {
+	var resp *http.Response
+	err := errCircuitOpen
+	for _, endpoint := range p.order() {
+		endpointResp, endpointErr := send(endpoint.CircuitBreaker)
+		if endpointErr == errCircuitOpen {
+			continue
+		}
+		resp, err = endpointResp, endpointErr
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		if !isEndpointFailure(resp, err) {
+			endpoint.forgive()
+			return resp, err
+		}
+		atomic.AddInt64(&endpoint.failures, 1)
+	}
+	return resp, err
+}
+
+func (e *poolEndpoint) forgive() {
+	for {
+		failures := atomic.LoadInt64(&e.failures)
+		if failures == 0 || atomic.CompareAndSwapInt64(&e.failures, failures, failures-1) {
+			return
+		}
+	}
+}
+
+// order returns the endpoints in the order they are attempted for the next report.
+func (p *EndpointPool) order() []*poolEndpoint {
+	if p.policy == Failover {
+		return p.endpoints
+	}
+	start := int((atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.endpoints)))
+	order := make([]*poolEndpoint, 0, len(p.endpoints))
+	order = append(order, p.endpoints[start:]...)
+	order = append(order, p.endpoints[:start]...)
+	if p.policy == LeastFailures {
+		// ties are broken in round-robin order.
+		sort.SliceStable(order, func(i, j int) bool {
+			return atomic.LoadInt64(&order[i].failures) < atomic.LoadInt64(&order[j].failures)
+		})
+	}
+	return order
+}
+
+// Allows reports whether an endpoint would currently let a report through.
+func (p *EndpointPool) Allows() bool {
+	for _, endpoint := range p.endpoints {
+		if endpoint.Allows() {
+			return true
+		}
+	}
+	return false
+}
+
+// States returns the state of the breaker of each endpoint, in the order the endpoints were given.
+func (p *EndpointPool) States() []CircuitState {
+	states := make([]CircuitState, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		states[i] = endpoint.State()
+	}
+	return states
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPool(policy LoadBalancingPolicy, n int) (*EndpointPool, []*fakeReporter, *time.Time) {
+	now := time.Now()
+	reporters := make([]*fakeReporter, n)
+	breakers := make([]*CircuitBreaker, n)
+	for i := range reporters {
+		reporters[i] = &fakeReporter{}
+		breakers[i] = NewCircuitBreaker(fmt.Sprint("endpoint-", i), reporters[i], 2, time.Minute)
+		breakers[i].now = func() time.Time { return now }
+	}
+	return NewEndpointPool(policy, breakers...), reporters, &now
+}
+
+func TestEndpointPool_RoundRobin(t *testing.T) {
+	pool, reporters, _ := newTestPool(RoundRobin, 3)
+	for i := 0; i < 6; i++ {
+		_, err := pool.Report(metricFormat, "line\n")
+		require.NoError(t, err)
+	}
+	for _, reporter := range reporters {
+		assert.Equal(t, 2, reporter.ReportCallCount())
+	}
+}
+
+func TestEndpointPool_FailoverAndReadmission(t *testing.T) {
+	pool, reporters, now := newTestPool(Failover, 2)
+	reporters[0].SetHTTPStatus(503)
+
+	for i := 0; i < 3; i++ {
+		resp, err := pool.Report(metricFormat, "line\n")
+		require.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode, "failed over to the secondary")
+	}
+	assert.Equal(t, []CircuitState{CircuitOpen, CircuitClosed}, pool.States())
+	assert.Equal(t, 2, reporters[0].ReportCallCount(), "the primary is left out once unhealthy")
+	assert.Equal(t, 3, reporters[1].ReportCallCount())
+
+	reporters[0].SetHTTPStatus(0)
+	*now = now.Add(time.Minute)
+	_, err := pool.Report(metricFormat, "line\n")
+	require.NoError(t, err)
+	assert.Equal(t, []CircuitState{CircuitClosed, CircuitClosed}, pool.States(), "the primary is re-admitted")
+	assert.Equal(t, 3, reporters[0].ReportCallCount())
+	assert.Equal(t, 3, reporters[1].ReportCallCount())
+}
+
+func TestEndpointPool_LeastFailures(t *testing.T) {
+	pool, reporters, _ := newTestPool(LeastFailures, 3)
+	reporters[0].SetHTTPStatus(500)
+	_, _ = pool.Report(metricFormat, "line\n")
+	reporters[0].SetHTTPStatus(0)
+
+	for i := 0; i < 4; i++ {
+		_, err := pool.Report(metricFormat, "line\n")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, reporters[0].ReportCallCount(), "the endpoint that failed is avoided")
+	assert.Equal(t, 5, reporters[1].ReportCallCount()+reporters[2].ReportCallCount())
+}
+
+func TestEndpointPool_AllEndpointsDown(t *testing.T) {
+	pool, reporters, _ := newTestPool(RoundRobin, 2)
+	for _, reporter := range reporters {
+		reporter.SetHTTPStatus(503)
+	}
+	resp, err := pool.Report(metricFormat, "line\n")
+	require.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.True(t, pool.Allows())
+
+	_, _ = pool.Report(metricFormat, "line\n")
+	assert.False(t, pool.Allows())
+	_, err = pool.Report(metricFormat, "line\n")
+	assert.Equal(t, errCircuitOpen, err)
+}
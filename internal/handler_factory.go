@@ -11,6 +11,7 @@ type HandlerFactory struct {
 	tracesReporter     Reporter
 	flushInterval      time.Duration
 	bufferSize         int
+	registry           sdkmetrics.Registry
 	lineHandlerOptions []LineHandlerOption
 }
 
@@ -19,62 +20,64 @@ func NewHandlerFactory(
 	tracesReporter Reporter,
 	flushInterval time.Duration,
 	bufferSize int,
-	registry sdkmetrics.Registry) *HandlerFactory {
+	registry sdkmetrics.Registry,
+	options ...LineHandlerOption) *HandlerFactory {
 	return &HandlerFactory{
 		metricsReporter: metricsReporter,
 		tracesReporter:  tracesReporter,
 		flushInterval:   flushInterval,
 		bufferSize:      bufferSize,
-		lineHandlerOptions: []LineHandlerOption{
+		registry:        registry,
+		lineHandlerOptions: append([]LineHandlerOption{
 			SetRegistry(registry),
-		},
+		}, options...),
 	}
 }
 
-func (f *HandlerFactory) NewPointHandler(batchSize int) *RealLineHandler {
+func (f *HandlerFactory) NewPointHandler(batchSize int, options ...LineHandlerOption) *RealLineHandler {
 	return NewLineHandler(
 		f.metricsReporter,
 		metricFormat,
 		f.flushInterval,
 		batchSize,
 		f.bufferSize,
-		append(f.lineHandlerOptions,
+		f.options(f.registry.PointsTracker(), options,
 			SetHandlerPrefix("points"))...,
 	)
 }
 
-func (f *HandlerFactory) NewHistogramHandler(batchSize int) *RealLineHandler {
+func (f *HandlerFactory) NewHistogramHandler(batchSize int, options ...LineHandlerOption) *RealLineHandler {
 	return NewLineHandler(
 		f.metricsReporter,
 		histogramFormat,
 		f.flushInterval,
 		batchSize,
 		f.bufferSize,
-		append(f.lineHandlerOptions,
+		f.options(f.registry.HistogramsTracker(), options,
 			SetHandlerPrefix("histograms"))...,
 	)
 }
 
-func (f *HandlerFactory) NewSpanHandler(batchSize int) *RealLineHandler {
+func (f *HandlerFactory) NewSpanHandler(batchSize int, options ...LineHandlerOption) *RealLineHandler {
 	return NewLineHandler(
 		f.tracesReporter,
 		traceFormat,
 		f.flushInterval,
 		batchSize,
 		f.bufferSize,
-		append(f.lineHandlerOptions,
+		f.options(f.registry.SpansTracker(), options,
 			SetHandlerPrefix("spans"))...,
 	)
 }
 
-func (f *HandlerFactory) NewSpanLogHandler(batchSize int) *RealLineHandler {
+func (f *HandlerFactory) NewSpanLogHandler(batchSize int, options ...LineHandlerOption) *RealLineHandler {
 	return NewLineHandler(
 		f.tracesReporter,
 		spanLogsFormat,
 		f.flushInterval,
 		batchSize,
 		f.bufferSize,
-		append(f.lineHandlerOptions,
+		f.options(f.registry.SpanLogsTracker(), options,
 			SetHandlerPrefix("span_logs"))...,
 	)
 }
@@ -82,15 +85,24 @@ func (f *HandlerFactory) NewSpanLogHandler(batchSize int) *RealLineHandler {
 // NewEventHandler creates a RealLineHandler for the Event type
 // The Event handler always sets "ThrottleRequestsOnBackpressure" to true
 // And always uses a batch size of exactly 1.
-func (f *HandlerFactory) NewEventHandler() *RealLineHandler {
+func (f *HandlerFactory) NewEventHandler(options ...LineHandlerOption) *RealLineHandler {
 	return NewLineHandler(
 		f.metricsReporter,
 		eventFormat,
 		f.flushInterval,
 		1,
 		f.bufferSize,
-		append(f.lineHandlerOptions,
+		f.options(f.registry.EventsTracker(), options,
 			SetHandlerPrefix("events"),
 			ThrottleRequestsOnBackpressure())...,
 	)
 }
+
+// options returns the options shared by all handlers, followed by the ones specific to a handler.
+func (f *HandlerFactory) options(tracker sdkmetrics.SuccessTracker, options []LineHandlerOption, defaults ...LineHandlerOption) []LineHandlerOption {
+	result := make([]LineHandlerOption, 0, len(f.lineHandlerOptions)+len(defaults)+len(options)+1)
+	result = append(result, f.lineHandlerOptions...)
+	result = append(result, SetSuccessTracker(tracker))
+	result = append(result, defaults...)
+	return append(result, options...)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 	"github.com/wavefronthq/wavefront-sdk-go/internal"
@@ -35,28 +34,28 @@ func Line(name string, centroids histogram.Centroids, hgs map[histogram.Granular
 
 	if ts != 0 {
 		sb.WriteString(" ")
-		sb.WriteString(strconv.FormatInt(ts, 10))
+		internal.WriteInt(sb, ts)
 	}
 	// Preprocess line. We know len(hgs) > 0 here.
 	for _, centroid := range centroids.Compact() {
 		sb.WriteString(" #")
-		sb.WriteString(strconv.Itoa(centroid.Count))
+		internal.WriteInt(sb, int64(centroid.Count))
 		sb.WriteString(" ")
-		sb.WriteString(strconv.FormatFloat(centroid.Value, 'f', -1, 64))
+		internal.WriteFloat(sb, centroid.Value)
 	}
 	sb.WriteString(" ")
-	sb.WriteString(strconv.Quote(internal.Sanitize(name)))
+	internal.WriteQuotedSanitized(sb, name)
 	sb.WriteString(" source=")
-	sb.WriteString(internal.SanitizeValue(source))
+	internal.WriteSanitizedValue(sb, source)
 
 	for k, v := range tags {
 		if v == "" {
 			return "", fmt.Errorf("tag values cannot be empty: histogram=%s tag=%s", name, k)
 		}
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(internal.Sanitize(k)))
+		internal.WriteQuotedSanitized(sb, k)
 		sb.WriteString("=")
-		sb.WriteString(internal.SanitizeValue(v))
+		internal.WriteSanitizedValue(sb, v)
 	}
 	sbBytes := sb.Bytes()
 
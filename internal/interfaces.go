@@ -2,11 +2,30 @@
 // Interfaces within this package are not guaranteed to be backwards compatible between releases.
 package internal
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"strings"
+)
 
 // Reporter is an interface for reporting data to a Wavefront service.
 type Reporter interface {
 	Report(format string, pointLines string) (*http.Response, error)
+	// ReportContext is like Report, but gives up once ctx is done.
+	ReportContext(ctx context.Context, format string, pointLines string) (*http.Response, error)
+}
+
+// linesReporter is implemented by reporters that encode a batch of lines without joining them first.
+type linesReporter interface {
+	ReportLines(ctx context.Context, format string, lines []string) (*http.Response, error)
+}
+
+// reportLines reports lines with reporter, joining them only if reporter cannot take them as they are.
+func reportLines(ctx context.Context, reporter Reporter, format string, lines []string) (*http.Response, error) {
+	if r, ok := reporter.(linesReporter); ok {
+		return r.ReportLines(ctx, format, lines)
+	}
+	return reporter.ReportContext(ctx, format, strings.Join(lines, ""))
 }
 
 type Flusher interface {
@@ -26,10 +45,16 @@ type ConnectionHandler interface {
 
 type LineHandler interface {
 	HandleLine(line string) error
+	HandleLineContext(ctx context.Context, line string) error
+	// HandleLines buffers several lines at once, and returns the error of each line, or nil
+	// if they were all buffered.
+	HandleLines(ctx context.Context, lines []string) []error
 	Start()
 	Stop()
+	Drain(ctx context.Context) DrainResult
 	Flush() error
-	FlushWithThrottling() error
+	FlushContext(ctx context.Context) error
+	FlushWithThrottling(ctx context.Context) error
 	GetFailureCount() int64
 	Format() string
 }
@@ -38,6 +63,7 @@ const (
 	contentType     = "Content-Type"
 	contentEncoding = "Content-Encoding"
 	gzipFormat      = "gzip"
+	deflateFormat   = "deflate"
 
 	octetStream     = "application/octet-stream"
 	applicationJSON = "application/json"
@@ -0,0 +1,25 @@
+// Package key identifies metrics by their name and tags.
+package key
+
+import (
+	"sort"
+	"strings"
+)
+
+// Of identifies the metric called name with tags, whatever the order of the tags.
+func Of(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, k := range keys {
+		sb.WriteString("\x00")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(tags[k])
+	}
+	return sb.String()
+}
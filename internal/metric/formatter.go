@@ -3,7 +3,6 @@ package metric
 import (
 	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/wavefronthq/wavefront-sdk-go/internal"
 )
@@ -23,26 +22,26 @@ func Line(name string, value float64, ts int64, source string, tags map[string]s
 	sb := internal.GetBuffer()
 	defer internal.PutBuffer(sb)
 
-	sb.WriteString(strconv.Quote(internal.Sanitize(name)))
+	internal.WriteQuotedSanitized(sb, name)
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	internal.WriteFloat(sb, value)
 
 	if ts != 0 {
 		sb.WriteString(" ")
-		sb.WriteString(strconv.FormatInt(ts, 10))
+		internal.WriteInt(sb, ts)
 	}
 
 	sb.WriteString(" source=")
-	sb.WriteString(internal.SanitizeValue(source))
+	internal.WriteSanitizedValue(sb, source)
 
 	for k, v := range tags {
 		if v == "" {
 			return "", fmt.Errorf("tag values cannot be empty: metric=%s tag=%s", name, k)
 		}
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(internal.Sanitize(k)))
+		internal.WriteQuotedSanitized(sb, k)
 		sb.WriteString("=")
-		sb.WriteString(internal.SanitizeValue(v))
+		internal.WriteSanitizedValue(sb, v)
 	}
 	sb.WriteString("\n")
 	return sb.String(), nil
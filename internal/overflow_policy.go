@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what a RealLineHandler does with a line when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest drops the line being handled.
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest evicts the oldest buffered line to make room for the line being handled.
+	OverflowDropOldest
+	// OverflowBlock blocks the caller until there is room in the buffer, the block timeout
+	// elapses or the context of the call is done, in which case the line is dropped.
+	OverflowBlock
+	// OverflowSpillToDisk writes the line to the persistent buffer of the handler,
+	// and drops it if there is none or it is full.
+	OverflowSpillToDisk
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowBlock:
+		return "block"
+	case OverflowSpillToDisk:
+		return "spill-to-disk"
+	default:
+		return "drop-newest"
+	}
+}
+
+// SetOverflowPolicy sets what the handler does with lines that do not fit in its buffer.
+// blockTimeout bounds how long OverflowBlock blocks a caller; 0 means until the context of the call is done.
+func SetOverflowPolicy(policy OverflowPolicy, blockTimeout time.Duration) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.overflowPolicy = policy
+		handler.blockTimeout = blockTimeout
+	}
+}
+
+// overflow applies the overflow policy to a line that did not fit in the buffer.
+// Lines handled on behalf of the flusher itself are never blocked on, as it is the one making room.
+// A buffer that can hold no line, such as the closed buffer of a drained handler, drops the line
+// whatever the policy, since there is nothing to evict and no room to wait for.
+func (lh *RealLineHandler) overflow(ctx context.Context, line string, canBlock bool) error {
+	buffer := lh.buffer
+	switch lh.overflowPolicy {
+	case OverflowDropOldest:
+		if buffer.open() {
+			lh.evictOldest(buffer, line)
+			return nil
+		}
+	case OverflowBlock:
+		if canBlock && buffer.open() {
+			return lh.block(ctx, buffer, line)
+		}
+	case OverflowSpillToDisk:
+		if lh.persistentBuffer != nil {
+			if _, err := lh.persistentBuffer.Write([]string{line}); err == nil {
+				lh.tracker.IncSpilled()
+				return nil
+			}
+		}
+	}
+	atomic.AddInt64(&lh.failures, 1)
+	return fmt.Errorf("buffer full, dropping line: %s", line)
+}
+
+// evictOldest drops buffered lines until line fits in the buffer.
+func (lh *RealLineHandler) evictOldest(buffer *ringBuffer, line string) {
+	for !buffer.offer(line) {
+		if _, ok := buffer.poll(); ok {
+			atomic.AddInt64(&lh.failures, 1)
+			lh.tracker.IncEvicted()
+		}
+	}
+}
+
+// block waits for room in buffer until the block timeout elapses, ctx is done or the handler is drained.
+func (lh *RealLineHandler) block(ctx context.Context, buffer *ringBuffer, line string) error {
+	lh.tracker.IncBlocked()
+	var timeout <-chan time.Time
+	if lh.blockTimeout > 0 {
+		timer := time.NewTimer(lh.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for !buffer.offer(line) {
+		select {
+		case <-buffer.waitForSpace():
+			// the room may have been made by Drain, which the line would be left behind by.
+			select {
+			case <-lh.stopped:
+				return lh.dropStopped(line)
+			default:
+			}
+		case <-timeout:
+			atomic.AddInt64(&lh.failures, 1)
+			return fmt.Errorf("buffer full after waiting %v, dropping line: %s", lh.blockTimeout, line)
+		case <-ctx.Done():
+			atomic.AddInt64(&lh.failures, 1)
+			return fmt.Errorf("buffer full, dropping line: %s: %w", line, ctx.Err())
+		case <-lh.stopped:
+			return lh.dropStopped(line)
+		}
+	}
+	if buffer.Len() < buffer.Cap() {
+		buffer.signal()
+	}
+	return nil
+}
+
+func (lh *RealLineHandler) dropStopped(line string) error {
+	atomic.AddInt64(&lh.failures, 1)
+	return fmt.Errorf("buffer full, dropping line: %s: handler stopped", line)
+}
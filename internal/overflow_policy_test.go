@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverflow_DropNewest(t *testing.T) {
+	tracker := &countingTracker{}
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 2, SetSuccessTracker(tracker))
+
+	addLines(lh, 2, 2, t)
+	assert.Error(t, lh.HandleLine("newest\n"))
+	assert.Equal(t, "dummyLine", poll(t, lh))
+	assert.Equal(t, int64(1), lh.GetFailureCount())
+}
+
+func TestOverflow_DropNewestOnRequeue(t *testing.T) {
+	tracker := &countingTracker{}
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 2, SetSuccessTracker(tracker))
+
+	addLines(lh, 2, 2, t)
+	lh.requeue("requeued\n")
+	assert.Equal(t, 1, tracker.dropped)
+	assert.Equal(t, int64(1), lh.GetFailureCount())
+}
+
+func TestOverflow_DropOldest(t *testing.T) {
+	tracker := &countingTracker{}
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 2,
+		SetOverflowPolicy(OverflowDropOldest, 0),
+		SetSuccessTracker(tracker))
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, lh.HandleLine(fmt.Sprintf("line-%d\n", i)))
+	}
+	assert.Equal(t, "line-2\n", poll(t, lh))
+	assert.Equal(t, "line-3\n", poll(t, lh))
+	assert.Equal(t, 2, tracker.evicted)
+	assert.Equal(t, int64(2), lh.GetFailureCount())
+}
+
+func TestOverflow_BlockTimesOut(t *testing.T) {
+	tracker := &countingTracker{}
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 1,
+		SetOverflowPolicy(OverflowBlock, 10*time.Millisecond),
+		SetSuccessTracker(tracker))
+
+	require.NoError(t, lh.HandleLine("first\n"))
+	start := time.Now()
+	assert.Error(t, lh.HandleLine("second\n"))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	assert.Equal(t, 1, tracker.blocked)
+	assert.Equal(t, int64(1), lh.GetFailureCount())
+}
+
+func TestOverflow_BlockUntilFlushed(t *testing.T) {
+	reporter := &fakeReporter{}
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 1,
+		SetOverflowPolicy(OverflowBlock, 0))
+
+	require.NoError(t, lh.HandleLine("first\n"))
+	done := make(chan error)
+	go func() {
+		done <- lh.HandleLine("second\n")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, lh.Flush())
+	assert.NoError(t, <-done)
+	assert.Equal(t, "second\n", poll(t, lh))
+	assert.Equal(t, []string{"first\n"}, reporter.lines)
+}
+
+func TestOverflow_BlockHonorsContext(t *testing.T) {
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 1,
+		SetOverflowPolicy(OverflowBlock, time.Hour))
+
+	require.NoError(t, lh.HandleLine("first\n"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := lh.HandleLineContext(ctx, "second\n")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOverflow_SpillToDiskWithoutPersistentBufferDrops(t *testing.T) {
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 1,
+		SetOverflowPolicy(OverflowSpillToDisk, 0))
+
+	require.NoError(t, lh.HandleLine("first\n"))
+	assert.Error(t, lh.HandleLine("second\n"))
+}
+
+func TestOverflow_WithoutRoom(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowDropOldest, OverflowBlock} {
+		t.Run(policy.String(), func(t *testing.T) {
+			stopped := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 2,
+				SetOverflowPolicy(policy, 0))
+			stopped.Stop()
+			empty := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 0,
+				SetOverflowPolicy(policy, 0))
+
+			for _, lh := range []*RealLineHandler{stopped, empty} {
+				done := make(chan error)
+				go func() {
+					done <- lh.HandleLine("line\n")
+				}()
+				select {
+				case err := <-done:
+					assert.Error(t, err)
+				case <-time.After(time.Second):
+					t.Fatal("HandleLine did not return")
+				}
+				assert.Equal(t, int64(1), lh.GetFailureCount())
+			}
+		})
+	}
+}
+
+func TestOverflow_DrainWakesUpBlockedWriters(t *testing.T) {
+	lh := NewLineHandler(&fakeReporter{error: errors.New("unavailable")}, metricFormat, time.Hour, 10, 1,
+		SetOverflowPolicy(OverflowBlock, 0))
+
+	require.NoError(t, lh.HandleLine("first\n"))
+	done := make(chan error)
+	go func() {
+		done <- lh.HandleLine("second\n")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	result := lh.Drain(context.Background())
+	assert.Equal(t, 1, result.Lost)
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "handler stopped")
+	case <-time.After(time.Second):
+		t.Fatal("HandleLine did not return")
+	}
+}
+
+type countingTracker struct {
+	valid   int
+	invalid int
+	dropped int
+	blocked int
+	evicted int
+	spilled int
+	limited int
+}
+
+func (c *countingTracker) IncValid()       { c.valid++ }
+func (c *countingTracker) IncInvalid()     { c.invalid++ }
+func (c *countingTracker) IncDropped()     { c.dropped++ }
+func (c *countingTracker) IncBlocked()     { c.blocked++ }
+func (c *countingTracker) IncEvicted()     { c.evicted++ }
+func (c *countingTracker) IncSpilled()     { c.spilled++ }
+func (c *countingTracker) IncRateLimited() { c.limited++ }
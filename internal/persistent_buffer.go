@@ -0,0 +1,460 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentExtension   = ".seg"
+	cursorFileName     = "cursor"
+	recordHeaderSize   = 8
+	maxSegmentSize     = 8 << 20
+	minSegmentSize     = 4 << 10
+	maxRecordSize      = 64 << 20
+	persistentFileMode = 0o640
+	persistentDirMode  = 0o750
+)
+
+var (
+	errPersistentBufferFull   = errors.New("error: persistent buffer full")
+	errPersistentBufferClosed = errors.New("error: persistent buffer closed")
+)
+
+// PersistentBuffer is a segmented on-disk FIFO queue of lines.
+//
+// Every record is framed as a 4-byte big-endian payload length, followed by a
+// 4-byte CRC-32 (IEEE) checksum of the payload, followed by the payload itself.
+// Records are appended to the newest segment file and consumed from the oldest
+// one; fully consumed segments are deleted. The read position is stored in a
+// cursor file so that lines are not replayed twice across restarts.
+//
+// When a buffer is opened, every segment is scanned and truncated at the first
+// torn or corrupted record, so a crash in the middle of a write only loses the
+// records from that point on. Writes are not fsync'ed individually: data
+// survives a process crash but not necessarily a power loss.
+//
+// A PersistentBuffer is safe for concurrent use, but a directory must not be
+// shared between processes.
+type PersistentBuffer struct {
+	mtx          sync.Mutex
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	segments   []*segment
+	nextID     uint64
+	readOffset int64
+	size       int64
+	lines      int
+
+	writer *os.File
+	closed bool
+}
+
+type segment struct {
+	id    uint64
+	size  int64
+	lines int
+}
+
+func (s *segment) path(dir string) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", s.id, segmentExtension))
+}
+
+// OpenPersistentBuffer opens, or creates, the PersistentBuffer stored in dir.
+// maxBytes caps the total size of the segment files.
+func OpenPersistentBuffer(dir string, maxBytes int64) (*PersistentBuffer, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid persistent buffer size: %d", maxBytes)
+	}
+	if err := os.MkdirAll(dir, persistentDirMode); err != nil {
+		return nil, err
+	}
+
+	segmentBytes := maxBytes / 4
+	if segmentBytes > maxSegmentSize {
+		segmentBytes = maxSegmentSize
+	}
+	if segmentBytes < minSegmentSize {
+		segmentBytes = maxBytes
+	}
+
+	pb := &PersistentBuffer{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: segmentBytes,
+	}
+	if err := pb.recover(); err != nil {
+		return nil, err
+	}
+	return pb, nil
+}
+
+// recover loads the segments found on disk, truncating any corrupted tail,
+// and restores the read position from the cursor file.
+func (pb *PersistentBuffer) recover() error {
+	entries, err := os.ReadDir(pb.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		pb.segments = append(pb.segments, &segment{id: id})
+	}
+	sort.Slice(pb.segments, func(i, j int) bool {
+		return pb.segments[i].id < pb.segments[j].id
+	})
+
+	cursorID, cursorOffset := pb.readCursor()
+	pb.nextID = cursorID
+	for len(pb.segments) > 0 && pb.segments[0].id < cursorID {
+		if err := os.Remove(pb.segments[0].path(pb.dir)); err != nil {
+			return err
+		}
+		pb.segments = pb.segments[1:]
+	}
+
+	for i, seg := range pb.segments {
+		readFrom := int64(0)
+		if i == 0 && seg.id == cursorID {
+			readFrom = cursorOffset
+		}
+		readFrom, err := pb.scan(seg, readFrom)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			pb.readOffset = readFrom
+		}
+		pb.size += seg.size
+		pb.lines += seg.lines
+		pb.nextID = seg.id + 1
+	}
+	for len(pb.segments) > 0 && pb.segments[0].lines == 0 {
+		if err := pb.dropHead(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan validates the records of seg, truncates the file at the first invalid
+// record and counts the records starting at or after readFrom. It returns the
+// read offset aligned to a record boundary.
+func (pb *PersistentBuffer) scan(seg *segment, readFrom int64) (int64, error) {
+	path := seg.path(pb.dir)
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("persistent buffer: truncating %s at offset %d: %v\n", path, offset, err)
+			if err := os.Truncate(path, offset); err != nil {
+				return 0, err
+			}
+			break
+		}
+		next := offset + recordSize(payload)
+		if next > readFrom {
+			if offset < readFrom {
+				// the cursor does not point to a record boundary, replay the whole record.
+				readFrom = offset
+			}
+			seg.lines++
+		}
+		offset = next
+	}
+	seg.size = offset
+	if readFrom > offset {
+		readFrom = offset
+	}
+	return readFrom, nil
+}
+
+func recordSize(payload []byte) int64 {
+	return int64(recordHeaderSize + len(payload))
+}
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated record header")
+		}
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if length > maxRecordSize {
+		return nil, fmt.Errorf("invalid record length %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.New("truncated record payload")
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errors.New("checksum mismatch")
+	}
+	return payload, nil
+}
+
+func appendRecord(buf []byte, line string) []byte {
+	var header [recordHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(line)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE([]byte(line)))
+	buf = append(buf, header[:]...)
+	return append(buf, line...)
+}
+
+func (pb *PersistentBuffer) readCursor() (uint64, int64) {
+	data, err := os.ReadFile(filepath.Join(pb.dir, cursorFileName))
+	if err != nil {
+		return 0, 0
+	}
+	var id uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &id, &offset); err != nil {
+		return 0, 0
+	}
+	return id, offset
+}
+
+func (pb *PersistentBuffer) writeCursor() error {
+	id, offset := pb.nextID, int64(0)
+	if len(pb.segments) > 0 {
+		id, offset = pb.segments[0].id, pb.readOffset
+	}
+	path := filepath.Join(pb.dir, cursorFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d", id, offset)), persistentFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Write appends lines to the buffer. It returns the number of lines written,
+// which is less than len(lines) only when an error occurred.
+func (pb *PersistentBuffer) Write(lines []string) (int, error) {
+	pb.mtx.Lock()
+	defer pb.mtx.Unlock()
+
+	if pb.closed {
+		return 0, errPersistentBufferClosed
+	}
+
+	var buf []byte
+	written, pending := 0, 0
+	for _, line := range lines {
+		rec := int64(recordHeaderSize + len(line))
+		if pb.size+int64(len(buf))+rec > pb.maxBytes {
+			break
+		}
+		if pb.needsNewSegment(int64(len(buf)), rec) {
+			if err := pb.writeRecords(buf, pending); err != nil {
+				return written, err
+			}
+			written += pending
+			buf, pending = buf[:0], 0
+			if err := pb.rollSegment(); err != nil {
+				return written, err
+			}
+		}
+		buf = appendRecord(buf, line)
+		pending++
+	}
+	if err := pb.writeRecords(buf, pending); err != nil {
+		return written, err
+	}
+	written += pending
+	if written < len(lines) {
+		return written, errPersistentBufferFull
+	}
+	return written, nil
+}
+
+// needsNewSegment reports whether a record of size rec, written after the
+// buffered bytes, must go to a new segment.
+func (pb *PersistentBuffer) needsNewSegment(buffered, rec int64) bool {
+	if pb.writer == nil {
+		return true
+	}
+	used := pb.segments[len(pb.segments)-1].size + buffered
+	return used > 0 && used+rec > pb.segmentBytes
+}
+
+func (pb *PersistentBuffer) rollSegment() error {
+	if pb.writer != nil {
+		if err := pb.writer.Close(); err != nil {
+			return err
+		}
+		pb.writer = nil
+	}
+	seg := &segment{id: pb.nextID}
+	f, err := os.OpenFile(seg.path(pb.dir), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, persistentFileMode)
+	if err != nil {
+		return err
+	}
+	pb.nextID++
+	pb.writer = f
+	pb.segments = append(pb.segments, seg)
+	return nil
+}
+
+func (pb *PersistentBuffer) writeRecords(buf []byte, n int) error {
+	if n == 0 {
+		return nil
+	}
+	if _, err := pb.writer.Write(buf); err != nil {
+		return err
+	}
+	current := pb.segments[len(pb.segments)-1]
+	current.size += int64(len(buf))
+	current.lines += n
+	pb.size += int64(len(buf))
+	pb.lines += n
+	return nil
+}
+
+// Read removes and returns up to n lines from the head of the buffer.
+func (pb *PersistentBuffer) Read(n int) ([]string, error) {
+	pb.mtx.Lock()
+	defer pb.mtx.Unlock()
+
+	if pb.closed {
+		return nil, errPersistentBufferClosed
+	}
+
+	var lines []string
+	for len(lines) < n && len(pb.segments) > 0 {
+		seg := pb.segments[0]
+		read, err := pb.readSegment(seg, n-len(lines))
+		lines = append(lines, read...)
+		if err != nil {
+			// the rest of the segment cannot be trusted, discard it.
+			pb.lines -= seg.lines
+			seg.lines = 0
+			if dropErr := pb.dropHead(); dropErr != nil {
+				log.Println(dropErr)
+			}
+			_ = pb.writeCursor()
+			return lines, err
+		}
+		if seg.lines > 0 {
+			break
+		}
+		if err := pb.dropHead(); err != nil {
+			return lines, err
+		}
+	}
+	return lines, pb.writeCursor()
+}
+
+func (pb *PersistentBuffer) readSegment(seg *segment, n int) ([]string, error) {
+	if seg.lines == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(seg.path(pb.dir))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(io.NewSectionReader(f, pb.readOffset, seg.size-pb.readOffset))
+	var lines []string
+	for len(lines) < n && seg.lines > 0 {
+		payload, err := readRecord(r)
+		if err != nil {
+			return lines, fmt.Errorf("persistent buffer: unable to read %s: %v", seg.path(pb.dir), err)
+		}
+		lines = append(lines, string(payload))
+		pb.readOffset += recordSize(payload)
+		seg.lines--
+		pb.lines--
+	}
+	return lines, nil
+}
+
+// dropHead deletes the oldest segment once it has been fully consumed.
+func (pb *PersistentBuffer) dropHead() error {
+	seg := pb.segments[0]
+	if len(pb.segments) == 1 && pb.writer != nil {
+		if err := pb.writer.Close(); err != nil {
+			return err
+		}
+		pb.writer = nil
+	}
+	if err := os.Remove(seg.path(pb.dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	pb.size -= seg.size
+	pb.readOffset = 0
+	pb.segments = pb.segments[1:]
+	return nil
+}
+
+// Len returns the number of unread lines in the buffer, 0 once it is closed, since they can
+// no longer be read.
+func (pb *PersistentBuffer) Len() int {
+	pb.mtx.Lock()
+	defer pb.mtx.Unlock()
+	if pb.closed {
+		return 0
+	}
+	return pb.lines
+}
+
+// Size returns the number of bytes used on disk by the buffer.
+func (pb *PersistentBuffer) Size() int64 {
+	pb.mtx.Lock()
+	defer pb.mtx.Unlock()
+	return pb.size
+}
+
+// Close syncs and closes the buffer. Unread lines are kept on disk.
+func (pb *PersistentBuffer) Close() error {
+	pb.mtx.Lock()
+	defer pb.mtx.Unlock()
+
+	if pb.closed {
+		return nil
+	}
+	pb.closed = true
+	if pb.writer == nil {
+		return nil
+	}
+	syncErr := pb.writer.Sync()
+	closeErr := pb.writer.Close()
+	pb.writer = nil
+	if syncErr != nil {
+		return syncErr
+	}
+	return closeErr
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistentBuffer_WriteRead(t *testing.T) {
+	pb, err := OpenPersistentBuffer(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	defer pb.Close()
+
+	written, err := pb.Write([]string{"a\n", "b\n", "c\n"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, written)
+	assert.Equal(t, 3, pb.Len())
+
+	lines, err := pb.Read(2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a\n", "b\n"}, lines)
+	assert.Equal(t, 1, pb.Len())
+
+	lines, err = pb.Read(10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c\n"}, lines)
+	assert.Equal(t, 0, pb.Len())
+	assert.Equal(t, int64(0), pb.Size())
+}
+
+func TestPersistentBuffer_ReopenReplaysUnreadLines(t *testing.T) {
+	dir := t.TempDir()
+	pb, err := OpenPersistentBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	_, err = pb.Write(makeLines(10))
+	require.NoError(t, err)
+	_, err = pb.Read(4)
+	require.NoError(t, err)
+	require.NoError(t, pb.Close())
+	assert.Equal(t, 0, pb.Len(), "closed buffers have no line to read")
+
+	pb, err = OpenPersistentBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	defer pb.Close()
+	assert.Equal(t, 6, pb.Len())
+	lines, err := pb.Read(10)
+	require.NoError(t, err)
+	assert.Equal(t, makeLines(10)[4:], lines)
+}
+
+func TestPersistentBuffer_RollsSegments(t *testing.T) {
+	dir := t.TempDir()
+	pb, err := OpenPersistentBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	defer pb.Close()
+	pb.segmentBytes = 64
+
+	_, err = pb.Write(makeLines(20))
+	require.NoError(t, err)
+	assert.Greater(t, len(segmentFiles(t, dir)), 1)
+
+	lines, err := pb.Read(20)
+	require.NoError(t, err)
+	assert.Equal(t, makeLines(20), lines)
+	assert.Empty(t, segmentFiles(t, dir))
+
+	_, err = pb.Write([]string{"after\n"})
+	require.NoError(t, err)
+	lines, err = pb.Read(1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"after\n"}, lines)
+}
+
+func TestPersistentBuffer_MaxBytes(t *testing.T) {
+	pb, err := OpenPersistentBuffer(t.TempDir(), 3*(recordHeaderSize+2))
+	require.NoError(t, err)
+	defer pb.Close()
+
+	written, err := pb.Write([]string{"a\n", "b\n", "c\n", "d\n"})
+	assert.Equal(t, errPersistentBufferFull, err)
+	assert.Equal(t, 3, written)
+	assert.Equal(t, 3, pb.Len())
+}
+
+func TestPersistentBuffer_RecoversFromTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	pb, err := OpenPersistentBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	_, err = pb.Write(makeLines(3))
+	require.NoError(t, err)
+	require.NoError(t, pb.Close())
+
+	files := segmentFiles(t, dir)
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 10, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	pb, err = OpenPersistentBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	defer pb.Close()
+	assert.Equal(t, 3, pb.Len())
+
+	_, err = pb.Write([]string{"new\n"})
+	require.NoError(t, err)
+	lines, err := pb.Read(10)
+	require.NoError(t, err)
+	assert.Equal(t, append(makeLines(3), "new\n"), lines)
+}
+
+func TestPersistentBuffer_DetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	pb, err := OpenPersistentBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	_, err = pb.Write(makeLines(3))
+	require.NoError(t, err)
+	require.NoError(t, pb.Close())
+
+	files := segmentFiles(t, dir)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	secondRecord := recordHeaderSize + len(makeLines(1)[0])
+	data[secondRecord+recordHeaderSize] ^= 0xff
+	require.NoError(t, os.WriteFile(files[0], data, 0o640))
+
+	pb, err = OpenPersistentBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	defer pb.Close()
+	lines, err := pb.Read(10)
+	require.NoError(t, err)
+	assert.Equal(t, makeLines(1), lines)
+}
+
+func makeLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line-%d\n", i)
+	}
+	return lines
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExtension))
+	require.NoError(t, err)
+	return files
+}
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"strconv"
 	"sync"
 )
 
@@ -25,3 +26,15 @@ func PutBuffer(buf *bytes.Buffer) {
 	buf.Reset()
 	buffers.Put(buf)
 }
+
+// WriteInt writes the decimal representation of i to buf without allocating.
+func WriteInt(buf *bytes.Buffer, i int64) {
+	var scratch [20]byte
+	buf.Write(strconv.AppendInt(scratch[:0], i, 10))
+}
+
+// WriteFloat writes the shortest decimal representation of f to buf without allocating.
+func WriteFloat(buf *bytes.Buffer, f float64) {
+	var scratch [32]byte
+	buf.Write(strconv.AppendFloat(scratch[:0], f, 'f', -1, 64))
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitMode decides what a RealLineHandler does with lines exceeding its rate limit.
+type RateLimitMode int
+
+const (
+	// RateLimitQueue keeps the excess lines buffered until the rate limit lets them through.
+	RateLimitQueue RateLimitMode = iota
+	// RateLimitDrop drops the excess lines as they are handled.
+	RateLimitDrop
+)
+
+// SetRateLimit caps the rate at which the handler reports lines to linesPerSecond, with bursts of up to burst lines.
+// A burst of 0 defaults to one second worth of lines.
+func SetRateLimit(linesPerSecond float64, burst int, mode RateLimitMode) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.rateLimiter = newRateLimiter(linesPerSecond, burst)
+		handler.rateLimitMode = mode
+	}
+}
+
+// rateLimiter is a token bucket refilled with rate tokens per second, up to burst tokens.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mtx    sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// take takes up to n tokens and returns how many were taken.
+func (l *rateLimiter) take(n int) int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := l.now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	taken := int(math.Min(float64(n), math.Floor(l.tokens)))
+	l.tokens -= float64(taken)
+	return taken
+}
+
+// putBack returns n tokens that were taken but not used.
+func (l *rateLimiter) putBack(n int) {
+	if n <= 0 {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.tokens = math.Min(l.burst, l.tokens+float64(n))
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Take(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(10, 5)
+	l.last = now
+	l.now = func() time.Time { return now }
+
+	assert.Equal(t, 5, l.take(8), "burst caps the first take")
+	assert.Equal(t, 0, l.take(1))
+
+	now = now.Add(300 * time.Millisecond)
+	assert.Equal(t, 3, l.take(8))
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, 5, l.take(8), "tokens do not accumulate beyond the burst")
+}
+
+func TestRateLimiter_DefaultBurst(t *testing.T) {
+	assert.Equal(t, 3.0, newRateLimiter(2.5, 0).burst)
+}
+
+func TestRateLimit_QueuesExcessLines(t *testing.T) {
+	reporter := &fakeReporter{}
+	tracker := &countingTracker{}
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100,
+		SetRateLimit(1, 4, RateLimitQueue),
+		SetSuccessTracker(tracker))
+	now := lh.rateLimiter.last
+	lh.rateLimiter.now = func() time.Time { return now }
+	addLines(lh, 10, 10, t)
+
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, 6, lh.buffered())
+	assert.Equal(t, 6, tracker.limited)
+
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, 1, reporter.ReportCallCount(), "nothing is reported without tokens")
+	assert.Equal(t, 6, tracker.limited, "lines are counted once however long they are deferred")
+
+	now = now.Add(2 * time.Second)
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, 4, lh.buffered())
+	addLines(lh, 5, 9, t)
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, 11, tracker.limited, "only the new lines are counted")
+
+	require.NoError(t, lh.FlushAll())
+	assert.Equal(t, 0, lh.buffered(), "draining ignores the rate limit")
+}
+
+func TestRateLimit_DropsExcessLines(t *testing.T) {
+	tracker := &countingTracker{}
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 100,
+		SetRateLimit(1, 2, RateLimitDrop),
+		SetSuccessTracker(tracker))
+	lh.rateLimiter.now = func() time.Time { return lh.rateLimiter.last }
+
+	assert.NoError(t, lh.HandleLine("a\n"))
+	assert.NoError(t, lh.HandleLine("b\n"))
+	assert.Error(t, lh.HandleLine("c\n"))
+	assert.Equal(t, 2, lh.buffer.Len())
+	assert.Equal(t, 1, tracker.limited)
+	assert.Equal(t, int64(1), lh.GetFailureCount())
+}
@@ -1,10 +1,12 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"net/http"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -27,23 +29,59 @@ type RealLineHandler struct {
 	// to guarantee 64-bit alignment on 32-bit machines.
 	// atomic.* functions crash if operands are not 64-bit aligned.
 	// See https://github.com/golang/go/issues/599
-	failures  int64
-	throttled int64
+	failures        int64
+	throttled       int64
+	delivered       int64
+	retryDropped    int64 // lines given up on by the retry policy, and not persisted
+	retryPersisted  int64 // lines given up on by the retry policy, and persisted
+	tickBatches     int64
+	lastTickBatches int64
 
 	Reporter      Reporter
 	BatchSize     int
+	MaxBatchBytes int
 	MaxBufferSize int
+	flushInterval time.Duration
+	flushWorkers  int
 	format        string
 
+	adaptiveFlush bool
+	lowWaterMark  int
+	flushBudget   time.Duration
+
 	internalRegistry       sdkmetrics.Registry
 	prefix                 string
 	throttleOnBackpressure bool
 	throttledSleepDuration time.Duration
 	mtx                    sync.Mutex
 
-	buffer   chan string
-	flusher  BackgroundFlusher
-	resumeAt time.Time
+	buffer   *ringBuffer
+	stopped  chan struct{} // closed by Drain, waking up the writers blocked on a full buffer
+	stopOnce sync.Once
+	drained  DrainResult // the result of Drain, set once
+	held     []string    // lines taken off the buffer but not reported yet, reported first
+	// the lines waiting to be reported that were already counted as rate limited:
+	// limited lines, after the first limitedAt ones.
+	limitedAt int
+	limited   int
+	flusher   BackgroundFlusher
+	resumeAt  time.Time
+
+	persistentDir      string
+	persistentMaxBytes int64
+	persistentBuffer   *PersistentBuffer
+
+	tracker        sdkmetrics.SuccessTracker
+	overflowPolicy OverflowPolicy
+	blockTimeout   time.Duration
+
+	rateLimiter   *rateLimiter
+	rateLimitMode RateLimitMode
+
+	retryPolicy   *RetryPolicy
+	retryBatch    []string
+	retryAttempts int // failed attempts to report retryBatch
+	retryAt       time.Time
 }
 
 func (lh *RealLineHandler) Format() string {
@@ -72,45 +110,248 @@ func ThrottleRequestsOnBackpressure() LineHandlerOption {
 	}
 }
 
+// SetFlushInterval overrides the interval at which the handler flushes in the background.
+func SetFlushInterval(interval time.Duration) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.flushInterval = interval
+	}
+}
+
+// SetFlushWorkers sets the number of batches the handler reports concurrently on every
+// flush interval, 1 by default.
+func SetFlushWorkers(workers int) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.flushWorkers = workers
+	}
+}
+
+// SetAdaptiveFlush makes each background flush keep reporting batches until no more than
+// lowWaterMark lines are buffered, for up to budget, instead of reporting a single batch.
+// A budget of 0 defaults to the flush interval.
+func SetAdaptiveFlush(lowWaterMark int, budget time.Duration) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.adaptiveFlush = true
+		handler.lowWaterMark = lowWaterMark
+		handler.flushBudget = budget
+	}
+}
+
+// SetBatchSize overrides the max number of lines reported per flush.
+func SetBatchSize(batchSize int) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.BatchSize = batchSize
+	}
+}
+
+// SetMaxBufferSize overrides the number of lines buffered in memory.
+func SetMaxBufferSize(maxBufferSize int) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.MaxBufferSize = maxBufferSize
+	}
+}
+
+// SetMaxBatchBytes caps the size of the uncompressed body of each report to maxBytes,
+// on top of the number of lines capped by the batch size. A line larger than maxBytes
+// is reported on its own.
+func SetMaxBatchBytes(maxBytes int) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.MaxBatchBytes = maxBytes
+	}
+}
+
+// SetSuccessTracker sets the tracker counting the lines that overflow the buffer of the handler.
+func SetSuccessTracker(tracker sdkmetrics.SuccessTracker) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.tracker = tracker
+	}
+}
+
+// SetPersistentBuffer persists lines to a PersistentBuffer stored in a subdirectory of dir
+// named after the handler prefix: lines left unreported on Stop, and lines that do not fit
+// in memory with OverflowSpillToDisk. maxBytes caps the disk usage of the handler.
+func SetPersistentBuffer(dir string, maxBytes int64) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.persistentDir = dir
+		handler.persistentMaxBytes = maxBytes
+	}
+}
+
+// SetRetryPolicy makes the handler hold a batch that failed to be reported and
+// retry it with exponential backoff, instead of buffering its lines again.
+func SetRetryPolicy(policy RetryPolicy) LineHandlerOption {
+	return func(handler *RealLineHandler) {
+		handler.retryPolicy = &policy
+	}
+}
+
 func NewLineHandler(reporter Reporter, format string, flushInterval time.Duration, batchSize, maxBufferSize int, setters ...LineHandlerOption) *RealLineHandler {
 	lh := &RealLineHandler{
 		Reporter:               reporter,
 		BatchSize:              batchSize,
 		MaxBufferSize:          maxBufferSize,
+		flushInterval:          flushInterval,
 		format:                 format,
 		throttledSleepDuration: defaultThrottledSleepDuration,
+		stopped:                make(chan struct{}),
 	}
 
-	lh.buffer = make(chan string, lh.MaxBufferSize)
-	lh.flusher = NewBackgroundFlusher(flushInterval, lh)
-
 	for _, setter := range setters {
 		setter(lh)
 	}
 
+	lh.buffer = newRingBuffer(lh.MaxBufferSize)
+	flusher := newBackgroundFlusher(lh.flushInterval, lh, lh.flushWorkers)
+	flusher.ticked = lh.endTick
+	lh.flusher = flusher
+	if lh.adaptiveFlush && lh.flushBudget <= 0 {
+		lh.flushBudget = lh.flushInterval
+	}
+
+	if lh.tracker == nil {
+		lh.tracker = sdkmetrics.NewNoOpRegistry().PointsTracker()
+	}
+
 	if lh.internalRegistry != nil {
 		lh.internalRegistry.NewGauge(lh.prefix+".queue.size", func() int64 {
-			return int64(len(lh.buffer))
+			return int64(lh.buffer.Len())
 		})
 		lh.internalRegistry.NewGauge(lh.prefix+".queue.remaining_capacity", func() int64 {
-			return int64(lh.MaxBufferSize - len(lh.buffer))
+			return int64(lh.MaxBufferSize - lh.buffer.Len())
 		})
+		if lh.adaptiveFlush {
+			lh.internalRegistry.NewGauge(lh.prefix+".flush.batches_per_tick", func() int64 {
+				return atomic.LoadInt64(&lh.lastTickBatches)
+			})
+		}
 	}
 	return lh
 }
 
+func (lh *RealLineHandler) openPersistentBuffer() {
+	name := lh.prefix
+	if name == "" {
+		name = lh.format
+	}
+	pb, err := OpenPersistentBuffer(filepath.Join(lh.persistentDir, name), lh.persistentMaxBytes)
+	if err != nil {
+		log.Printf("%s -- unable to open persistent buffer, lines will not be persisted: %v\n", lh.format, err)
+		return
+	}
+	lh.persistentBuffer = pb
+
+	if lh.internalRegistry != nil {
+		lh.internalRegistry.NewGauge(lh.prefix+".persistent_buffer.size", func() int64 {
+			return int64(pb.Len())
+		})
+		lh.internalRegistry.NewGauge(lh.prefix+".persistent_buffer.bytes", func() int64 {
+			return pb.Size()
+		})
+	}
+}
+
+// Start opens the persistent buffer, creating its directory if needed, replays the lines
+// persisted by a previous run and starts the background flusher.
 func (lh *RealLineHandler) Start() {
+	lh.mtx.Lock()
+	if lh.persistentDir != "" && lh.persistentBuffer == nil {
+		lh.openPersistentBuffer()
+	}
+	_, _ = lh.replay()
+	lh.mtx.Unlock()
 	lh.flusher.Start()
 }
 
 func (lh *RealLineHandler) HandleLine(line string) error {
-	select {
-	case lh.buffer <- line:
+	return lh.HandleLineContext(context.Background(), line)
+}
+
+// HandleLineContext buffers line, applying the overflow policy of the handler if the buffer is full.
+// With OverflowBlock, ctx bounds how long the call blocks.
+func (lh *RealLineHandler) HandleLineContext(ctx context.Context, line string) error {
+	if lh.rateLimiter != nil && lh.rateLimitMode == RateLimitDrop && lh.rateLimiter.take(1) == 0 {
+		return lh.rateLimited(line)
+	}
+	if lh.buffer.offer(line) {
 		return nil
-	default:
-		atomic.AddInt64(&lh.failures, 1)
-		return fmt.Errorf("buffer full, dropping line: %s", line)
 	}
+	return lh.overflow(ctx, line, true)
+}
+
+// HandleLines buffers lines like HandleLineContext, taking them from the rate limit all at once.
+// It returns nil when every line was buffered, and else the error of each line, nil for the
+// lines that were buffered.
+func (lh *RealLineHandler) HandleLines(ctx context.Context, lines []string) []error {
+	allowed := len(lines)
+	if lh.rateLimiter != nil && lh.rateLimitMode == RateLimitDrop {
+		allowed = lh.rateLimiter.take(len(lines))
+	}
+	var errs []error
+	for i, line := range lines {
+		var err error
+		if i >= allowed {
+			err = lh.rateLimited(line)
+		} else if !lh.buffer.offer(line) {
+			err = lh.overflow(ctx, line, true)
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(lines))
+			}
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (lh *RealLineHandler) rateLimited(line string) error {
+	atomic.AddInt64(&lh.failures, 1)
+	lh.tracker.IncRateLimited()
+	return fmt.Errorf("rate limit exceeded, dropping line: %s", line)
+}
+
+// requeue buffers a line on behalf of the flusher, which must not block on its own buffer.
+// The lines the overflow policy drops are counted as dropped, as the sender does for the lines it handles.
+func (lh *RealLineHandler) requeue(line string) {
+	if lh.buffer.offer(line) {
+		return
+	}
+	if err := lh.overflow(context.Background(), line, false); err != nil {
+		lh.tracker.IncDropped()
+	}
+}
+
+// replay moves persisted lines back into the in-memory buffer, as far as there is room for them.
+// It returns the number of lines moved, and the error reading the persistent buffer, if any.
+func (lh *RealLineHandler) replay() (int, error) {
+	if lh.persistentBuffer == nil || lh.persistentBuffer.Len() == 0 {
+		return 0, nil
+	}
+	room := lh.buffer.Cap() - lh.buffer.Len()
+	if room <= 0 {
+		return 0, nil
+	}
+	lines, err := lh.persistentBuffer.Read(room)
+	if err != nil {
+		log.Printf("%s -- error replaying persisted lines: %v\n", lh.format, err)
+	}
+	for i, line := range lines {
+		if !lh.buffer.offer(line) {
+			lh.persist(lines[i:])
+			return i, err
+		}
+	}
+	return len(lines), err
+}
+
+// persist writes lines to the persistent buffer, dropping the ones that do not fit.
+// It returns the number of lines written.
+func (lh *RealLineHandler) persist(lines []string) int {
+	written, err := lh.persistentBuffer.Write(lines)
+	if err != nil {
+		atomic.AddInt64(&lh.failures, int64(len(lines)-written))
+		log.Printf("%s -- unable to persist %d lines: %v\n", lh.format, len(lines)-written, err)
+	}
+	return written
 }
 
 func minInt(x, y int) int {
@@ -120,86 +361,368 @@ func minInt(x, y int) int {
 	return y
 }
 
-func (lh *RealLineHandler) flush() error {
+// flush reports a batch of buffered lines, and returns the number of lines it attempted to report.
+// The lock is only held while taking the batch and while handling the outcome of the report,
+// so that several flush workers can report batches concurrently.
+func (lh *RealLineHandler) flush(ctx context.Context) (int, error) {
+	lh.mtx.Lock()
+	lines, attempts, err := lh.nextBatch()
+	lh.mtx.Unlock()
+	if err != nil || len(lines) == 0 {
+		return 0, err
+	}
+	atomic.AddInt64(&lh.tickBatches, 1)
+	resp, err := reportLines(ctx, lh.Reporter, lh.format, lines)
 	lh.mtx.Lock()
 	defer lh.mtx.Unlock()
-	bufLen := len(lh.buffer)
-	if bufLen > 0 {
-		size := minInt(bufLen, lh.BatchSize)
-		lines := make([]string, size)
-		for i := 0; i < size; i++ {
-			lines[i] = <-lh.buffer
+	return len(lines), lh.handleReport(ctx, lines, attempts, resp, err)
+}
+
+// endTick records the number of batches reported during the background flush that just ended.
+func (lh *RealLineHandler) endTick() {
+	atomic.StoreInt64(&lh.lastTickBatches, atomic.SwapInt64(&lh.tickBatches, 0))
+}
+
+// nextBatch takes the next batch to report, along with the number of failed attempts to report it:
+// the batch held by the retry policy if any, or a batch of buffered lines otherwise.
+func (lh *RealLineHandler) nextBatch() ([]string, int, error) {
+	if !lh.reporterAllows() {
+		return nil, 0, errCircuitOpen
+	}
+	if lh.retryBatch != nil {
+		lines, attempts := lh.takeRetryBatch()
+		return lines, attempts, nil
+	}
+	_, _ = lh.replay()
+	size := minInt(lh.buffered(), lh.BatchSize)
+	if size == 0 || lh.rateLimiter == nil || lh.rateLimitMode != RateLimitQueue {
+		return lh.takeBatch(size), 0, nil
+	}
+	allowed := lh.rateLimiter.take(size)
+	lines := lh.takeBatch(allowed)
+	lh.rateLimiter.putBack(allowed - len(lines))
+	lh.countRateLimited(size - len(lines))
+	return lines, 0, nil
+}
+
+// countRateLimited counts the first deferred lines waiting to be reported as rate limited,
+// except for the ones that were already counted on a previous flush.
+func (lh *RealLineHandler) countRateLimited(deferred int) {
+	end := lh.limitedAt + lh.limited
+	counted := 0
+	if deferred > lh.limitedAt {
+		counted = minInt(deferred, end) - lh.limitedAt
+	}
+	for i := counted; i < deferred; i++ {
+		lh.tracker.IncRateLimited()
+	}
+	if deferred < lh.limitedAt {
+		// the lines counted before are no longer next to the ones counted now, and are forgotten.
+		lh.limitedAt, lh.limited = 0, deferred
+		return
+	}
+	lh.limitedAt = 0
+	if lh.limited = end; deferred > end {
+		lh.limited = deferred
+	}
+}
+
+// buffered returns the number of lines waiting to be reported.
+func (lh *RealLineHandler) buffered() int {
+	return len(lh.held) + lh.buffer.Len()
+}
+
+// takeBatch takes up to max lines off the buffer, as long as their total size stays within
+// MaxBatchBytes. A line larger than MaxBatchBytes makes up a batch on its own.
+func (lh *RealLineHandler) takeBatch(max int) []string {
+	lines := make([]string, 0, max)
+	size := 0
+	for len(lines) < max {
+		line, ok := lh.next()
+		if !ok {
+			break
 		}
-		return lh.report(lines)
+		if lh.MaxBatchBytes > 0 && len(lines) > 0 && size+len(line) > lh.MaxBatchBytes {
+			lh.unshift([]string{line})
+			break
+		}
+		size += len(line)
+		lines = append(lines, line)
 	}
-	return nil
+	return lines
+}
+
+// next takes the next line to report off the buffer.
+func (lh *RealLineHandler) next() (string, bool) {
+	if lh.limitedAt > 0 {
+		lh.limitedAt--
+	} else if lh.limited > 0 {
+		lh.limited--
+	}
+	if len(lh.held) > 0 {
+		line := lh.held[0]
+		lh.held = lh.held[1:]
+		return line, true
+	}
+	return lh.buffer.poll()
 }
 
-func (lh *RealLineHandler) FlushWithThrottling() error {
-	if time.Now().Before(lh.resumeAt) {
+// unshift puts lines back in front of the buffer.
+func (lh *RealLineHandler) unshift(lines []string) {
+	if len(lines) > 0 {
+		lh.limitedAt += len(lines)
+		lh.held = append(append(make([]string, 0, len(lines)+len(lh.held)), lines...), lh.held...)
+	}
+}
+
+// reporterAllows reports whether the Reporter would attempt a report, so that
+// lines are left in the buffer while a circuit breaker is open.
+func (lh *RealLineHandler) reporterAllows() bool {
+	g, ok := lh.Reporter.(gate)
+	return !ok || g.Allows()
+}
+
+// FlushWithThrottling flushes a batch, unless the server asked to back off. With an adaptive flush,
+// it keeps flushing batches until the buffer is down to the low-water mark, a flush fails or
+// the flush budget is spent. ctx cancels both the flush and waiting for the end of a throttling period.
+func (lh *RealLineHandler) FlushWithThrottling(ctx context.Context) error {
+	if resumeAt := lh.throttledUntil(); time.Now().Before(resumeAt) {
 		log.Println("attempting to flush, but flushing is currently throttled by the server")
-		log.Printf("sleeping until: %s\n", lh.resumeAt.Format(time.RFC3339))
-		time.Sleep(time.Until(lh.resumeAt))
+		log.Printf("sleeping until: %s\n", resumeAt.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(resumeAt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
 	}
-	return lh.Flush()
+	if retryAt := lh.nextRetry(); time.Now().Before(retryAt) {
+		log.Printf("%s -- backing off until: %s\n", lh.format, retryAt.Format(time.RFC3339))
+		return nil
+	}
+	if !lh.adaptiveFlush {
+		return lh.FlushContext(ctx)
+	}
+	deadline := time.Now().Add(lh.flushBudget)
+	for {
+		reported, err := lh.flushContext(ctx)
+		if err != nil || reported == 0 || lh.backlog() <= lh.lowWaterMark || !time.Now().Before(deadline) {
+			return err
+		}
+	}
+}
+
+// backlog returns the number of lines waiting to be reported.
+func (lh *RealLineHandler) backlog() int {
+	lh.mtx.Lock()
+	defer lh.mtx.Unlock()
+	return lh.buffered()
+}
+
+func (lh *RealLineHandler) throttledUntil() time.Time {
+	lh.mtx.Lock()
+	defer lh.mtx.Unlock()
+	return lh.resumeAt
+}
+
+func (lh *RealLineHandler) nextRetry() time.Time {
+	lh.mtx.Lock()
+	defer lh.mtx.Unlock()
+	return lh.retryAt
 }
 
 func (lh *RealLineHandler) Flush() error {
-	flushErr := lh.flush()
+	return lh.FlushContext(context.Background())
+}
+
+// FlushContext reports a batch of buffered lines, giving up once ctx is done.
+// The lines of a batch that was given up on are kept to be reported later.
+func (lh *RealLineHandler) FlushContext(ctx context.Context) error {
+	_, err := lh.flushContext(ctx)
+	return err
+}
+
+func (lh *RealLineHandler) flushContext(ctx context.Context) (int, error) {
+	reported, flushErr := lh.flush(ctx)
 	if flushErr == errThrottled && lh.throttleOnBackpressure {
 		atomic.AddInt64(&lh.throttled, 1)
-		log.Printf("pausing requests for %v, buffer size: %d\n", lh.throttledSleepDuration, len(lh.buffer))
+		log.Printf("pausing requests for %v, buffer size: %d\n", lh.throttledSleepDuration, lh.buffer.Len())
+		lh.mtx.Lock()
 		lh.resumeAt = time.Now().Add(lh.throttledSleepDuration)
+		lh.mtx.Unlock()
 	}
-	return flushErr
+	return reported, flushErr
 }
 
+// FlushAll reports every buffered line, including the ones held in the persistent buffer.
 func (lh *RealLineHandler) FlushAll() error {
+	return lh.flushAll(context.Background())
+}
+
+func (lh *RealLineHandler) flushAll(ctx context.Context) error {
 	lh.mtx.Lock()
 	defer lh.mtx.Unlock()
-	bufLen := len(lh.buffer)
-	if bufLen > 0 {
-		var imod int
-		size := minInt(bufLen, lh.BatchSize)
-		lines := make([]string, size)
-		for i := 0; i < bufLen; i++ {
-			imod = i % size
-			lines[imod] = <-lh.buffer
-			if imod == size-1 { // report batch
-				if err := lh.report(lines); err != nil {
-					return err
-				}
-			}
+	if _, err := lh.replay(); err != nil {
+		return err
+	}
+	for {
+		if err := lh.flushBuffered(ctx); err != nil {
+			return err
+		}
+		if lh.persistentBuffer == nil || lh.persistentBuffer.Len() == 0 {
+			return nil
+		}
+		// stop once the persisted lines can no longer be replayed, rather than spin on them.
+		if moved, err := lh.replay(); err != nil || moved == 0 {
+			return err
+		}
+	}
+}
+
+func (lh *RealLineHandler) flushBuffered(ctx context.Context) error {
+	if !lh.reporterAllows() {
+		return errCircuitOpen
+	}
+	if lh.retryBatch != nil {
+		if err := lh.retry(ctx); err != nil {
+			return err
+		}
+	}
+	for remaining := lh.buffered(); remaining > 0; {
+		lines := lh.takeBatch(minInt(remaining, lh.BatchSize))
+		if len(lines) == 0 {
+			break
 		}
-		if imod < size-1 { // report remaining
-			return lh.report(lines[0 : imod+1])
+		remaining -= len(lines)
+		if err := lh.report(ctx, lines, 0); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (lh *RealLineHandler) report(lines []string) error {
-	strLines := strings.Join(lines, "")
-	resp, err := lh.Reporter.Report(lh.format, strLines)
+// report reports lines, which failed to be reported attempts times before.
+func (lh *RealLineHandler) report(ctx context.Context, lines []string, attempts int) error {
+	resp, err := reportLines(ctx, lh.Reporter, lh.format, lines)
+	return lh.handleReport(ctx, lines, attempts, resp, err)
+}
+
+// handleReport updates the handler according to the outcome of reporting lines,
+// which failed to be reported attempts times before.
+func (lh *RealLineHandler) handleReport(ctx context.Context, lines []string, attempts int, resp *http.Response, err error) error {
+	if err == errCircuitOpen {
+		lh.hold(lines, attempts)
+		return err
+	}
+
+	if err != nil && ctx.Err() != nil {
+		lh.hold(lines, attempts)
+		return fmt.Errorf("error reporting %s format data to Wavefront: %w", lh.format, ctx.Err())
+	}
 
 	if err != nil {
 		if shouldRetry(err) {
-			lh.bufferLines(lines)
+			lh.rebuffer(lines, attempts, nil)
 		}
 		return fmt.Errorf("error reporting %s format data to Wavefront: %q", lh.format, err)
 	}
 
 	if 400 <= resp.StatusCode && resp.StatusCode <= 599 {
 		atomic.AddInt64(&lh.failures, 1)
-		lh.bufferLines(lines)
+		lh.rebuffer(lines, attempts, resp)
 		if resp.StatusCode == 406 {
 			return errThrottled
 		}
 		return fmt.Errorf("error reporting %s format data to Wavefront. status=%d", lh.format, resp.StatusCode)
 	}
+	atomic.AddInt64(&lh.delivered, int64(len(lines)))
 	return nil
 }
 
+// retry reports the batch held by the retry policy.
+func (lh *RealLineHandler) retry(ctx context.Context) error {
+	lines, attempts := lh.takeRetryBatch()
+	return lh.report(ctx, lines, attempts)
+}
+
+// takeRetryBatch takes the batch held by the retry policy, along with the number of failed attempts to report it.
+func (lh *RealLineHandler) takeRetryBatch() ([]string, int) {
+	lines, attempts := lh.retryBatch, lh.retryAttempts
+	lh.retryBatch, lh.retryAttempts = nil, 0
+	return lines, attempts
+}
+
+// hold keeps the lines of a batch that was not attempted, or given up on,
+// without counting it as a failed attempt.
+func (lh *RealLineHandler) hold(lines []string, attempts int) {
+	if lh.retryPolicy != nil {
+		lh.holdForRetry(lines, attempts)
+		return
+	}
+	for _, line := range lines {
+		lh.requeue(line)
+	}
+}
+
+// rebuffer keeps the lines of a failed batch to report them again later.
+// Without a retry policy, the lines go back to the buffer and are retried on the next flush.
+// With a retry policy, the batch is retried as a whole after a backoff, until it runs out of attempts.
+// In both cases, a delay requested by the server through Retry-After is honored.
+func (lh *RealLineHandler) rebuffer(lines []string, attempts int, resp *http.Response) {
+	now := time.Now()
+	delay, hasRetryAfter := retryAfter(resp, now)
+
+	if lh.retryPolicy == nil {
+		if hasRetryAfter {
+			lh.retryAt = now.Add(delay)
+		}
+		lh.bufferLines(lines)
+		return
+	}
+
+	attempts++
+	if lh.retryPolicy.exhausted(attempts) {
+		lh.giveUp(lines, attempts)
+		return
+	}
+	if backoff := lh.retryPolicy.backoff(attempts); !hasRetryAfter || backoff > delay {
+		delay = backoff
+	}
+	log.Printf("%s -- error reporting to Wavefront. retrying %d lines in %v\n", lh.format, len(lines), delay)
+	lh.holdForRetry(lines, attempts)
+	lh.retryAt = now.Add(delay)
+}
+
+// giveUp gives up on reporting lines after attempts failed attempts. The lines are persisted if the
+// handler has a persistent buffer, to be reported once the ones buffered in memory are, and dropped otherwise.
+func (lh *RealLineHandler) giveUp(lines []string, attempts int) {
+	persisted := 0
+	if lh.persistentBuffer != nil {
+		log.Printf("%s -- persisting batch of %d lines after %d attempts\n", lh.format, len(lines), attempts)
+		persisted = lh.persist(lines)
+		atomic.AddInt64(&lh.retryPersisted, int64(persisted))
+	} else {
+		log.Printf("%s -- dropping batch of %d lines after %d attempts\n", lh.format, len(lines), attempts)
+		atomic.AddInt64(&lh.failures, int64(len(lines)))
+	}
+	atomic.AddInt64(&lh.retryDropped, int64(len(lines)-persisted))
+	for i := persisted; i < len(lines); i++ {
+		lh.tracker.IncDropped()
+	}
+}
+
+// holdForRetry makes lines, which failed to be reported attempts times, the batch retried by the
+// retry policy. If another flush worker already holds a batch, lines go back in front of the buffer
+// to be retried after it, as a new batch.
+func (lh *RealLineHandler) holdForRetry(lines []string, attempts int) {
+	if lh.retryBatch != nil {
+		lh.unshift(lines)
+		return
+	}
+	lh.retryBatch, lh.retryAttempts = lines, attempts
+}
+
 func shouldRetry(err error) bool {
 	switch err.(type) {
 	case *auth.Err:
@@ -211,7 +734,7 @@ func shouldRetry(err error) bool {
 func (lh *RealLineHandler) bufferLines(batch []string) {
 	log.Println("error reporting to Wavefront. buffering lines.")
 	for _, line := range batch {
-		_ = lh.HandleLine(line)
+		lh.requeue(line)
 	}
 }
 
@@ -225,9 +748,79 @@ func (lh *RealLineHandler) GetThrottledCount() int64 {
 }
 
 func (lh *RealLineHandler) Stop() {
+	if result := lh.Drain(context.Background()); result.Err != nil {
+		log.Println(result.Err)
+	}
+}
+
+// DrainResult counts what happened to the lines buffered by a handler when it was drained.
+type DrainResult struct {
+	// Delivered is the number of lines reported while draining.
+	Delivered int
+	// Persisted is the number of lines left unreported that were written to the persistent buffer.
+	Persisted int
+	// Lost is the number of lines left unreported that were dropped.
+	Lost int
+	// Err is the error that interrupted draining, if any.
+	Err error
+}
+
+// Drain stops the background flusher and reports every buffered line, giving up once ctx is
+// done or a report fails. Lines left unreported are persisted if the handler has a persistent
+// buffer, and lost otherwise. Lines handled afterwards are dropped. Draining the handler again
+// returns the result of the first drain.
+func (lh *RealLineHandler) Drain(ctx context.Context) DrainResult {
+	lh.stopOnce.Do(func() {
+		if lh.stopped != nil {
+			close(lh.stopped)
+		}
+		lh.drained = lh.drain(ctx)
+	})
+	return lh.drained
+}
+
+func (lh *RealLineHandler) drain(ctx context.Context) DrainResult {
 	lh.flusher.Stop()
-	if err := lh.FlushAll(); err != nil {
-		log.Println(err)
+	delivered := atomic.LoadInt64(&lh.delivered)
+	retryDropped, retryPersisted := atomic.LoadInt64(&lh.retryDropped), atomic.LoadInt64(&lh.retryPersisted)
+	result := DrainResult{Err: lh.flushAll(ctx)}
+	result.Delivered = int(atomic.LoadInt64(&lh.delivered) - delivered)
+	// the batches given up on while draining are persisted or lost as well.
+	retryLost := int(atomic.LoadInt64(&lh.retryDropped) - retryDropped)
+	result.Persisted = int(atomic.LoadInt64(&lh.retryPersisted) - retryPersisted)
+
+	// lines handled from now on are dropped, rather than left behind in the buffer.
+	lh.buffer.close()
+	remaining := lh.takeRemaining()
+	if len(remaining) > 0 && lh.persistentBuffer != nil {
+		log.Printf("%s -- persisting %d unreported lines\n", lh.format, len(remaining))
+		persisted := lh.persist(remaining)
+		result.Persisted += persisted
+		result.Lost = len(remaining) - persisted
+	} else if result.Lost = len(remaining); result.Lost > 0 {
+		log.Printf("%s -- dropping %d unreported lines\n", lh.format, result.Lost)
+	}
+	result.Lost += retryLost
+	if lh.persistentBuffer != nil {
+		if err := lh.persistentBuffer.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+	return result
+}
+
+// takeRemaining empties the handler of the lines that could not be reported.
+func (lh *RealLineHandler) takeRemaining() []string {
+	lh.mtx.Lock()
+	defer lh.mtx.Unlock()
+	lines := make([]string, 0, len(lh.retryBatch)+lh.buffered())
+	lines = append(lines, lh.retryBatch...)
+	lines = append(lines, lh.held...)
+	lh.retryBatch, lh.retryAttempts = nil, 0
+	lh.held = nil
+	lh.limitedAt, lh.limited = 0, 0
+	for line, ok := lh.buffer.poll(); ok; line, ok = lh.buffer.poll() {
+		lines = append(lines, line)
 	}
-	lh.buffer = nil
+	return lines
 }
@@ -1,13 +1,17 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wavefronthq/wavefront-sdk-go/internal/auth"
 )
 
@@ -15,6 +19,7 @@ type fakeReporter struct {
 	httpResponseStatus int64
 	reportCallCount    int64
 	error              error
+	header             http.Header
 	lines              []string
 }
 
@@ -25,12 +30,20 @@ func (reporter *fakeReporter) Report(_ string, lines string) (*http.Response, er
 	}
 	status := atomic.LoadInt64(&reporter.httpResponseStatus)
 	if status != 0 {
-		return &http.Response{StatusCode: int(status)}, nil
+		return &http.Response{StatusCode: int(status), Header: reporter.header}, nil
 	}
 	reporter.lines = append(reporter.lines, lines)
 	return &http.Response{StatusCode: 200}, nil
 }
 
+func (reporter *fakeReporter) ReportContext(ctx context.Context, format string, lines string) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		atomic.AddInt64(&reporter.reportCallCount, 1)
+		return nil, err
+	}
+	return reporter.Report(format, lines)
+}
+
 func (reporter *fakeReporter) ReportCallCount() int {
 	return int(atomic.LoadInt64(&reporter.reportCallCount))
 }
@@ -68,7 +81,7 @@ func TestBufferLines(t *testing.T) {
 	checkLength(lh.buffer, 100, "error buffering lines", t)
 
 	// clear lines
-	lh.buffer = make(chan string, 100)
+	lh.buffer = newRingBuffer(100)
 	checkLength(lh.buffer, 0, "error clearing lines", t)
 
 	addLines(lh, 90, 90, t)
@@ -98,7 +111,7 @@ func TestFlushWithThrottling_WhenThrottling_DelayUntilThrottleInterval(t *testin
 		Reporter:               &fakeReporter{},
 		MaxBufferSize:          100,
 		BatchSize:              10,
-		buffer:                 make(chan string, 100),
+		buffer:                 newRingBuffer(100),
 		throttleOnBackpressure: true,
 		throttledSleepDuration: 1 * time.Second,
 	}
@@ -108,12 +121,12 @@ func TestFlushWithThrottling_WhenThrottling_DelayUntilThrottleInterval(t *testin
 	startTime := time.Now().Add(1 * time.Second)
 	deadline := startTime.Add(1 * time.Second)
 	assert.Error(t, lh.Flush())
-	assert.Equal(t, 100, len(lh.buffer))
+	assert.Equal(t, 100, lh.buffer.Len())
 	assert.WithinRange(t, lh.resumeAt, startTime, deadline)
 	lh.Reporter.(*fakeReporter).SetHTTPStatus(0)
-	assert.NoError(t, lh.FlushWithThrottling())
+	assert.NoError(t, lh.FlushWithThrottling(context.Background()))
 	assert.Greater(t, time.Now(), lh.resumeAt)
-	assert.Equal(t, 90, len(lh.buffer))
+	assert.Equal(t, 90, lh.buffer.Len())
 }
 
 func TestBackgroundFlushWithThrottling_WhenThrottling_DelayUntilThrottleInterval(t *testing.T) {
@@ -121,7 +134,7 @@ func TestBackgroundFlushWithThrottling_WhenThrottling_DelayUntilThrottleInterval
 		Reporter:               &fakeReporter{},
 		MaxBufferSize:          100,
 		BatchSize:              10,
-		buffer:                 make(chan string, 100),
+		buffer:                 newRingBuffer(100),
 		throttleOnBackpressure: true,
 		throttledSleepDuration: 1 * time.Second,
 	}
@@ -131,12 +144,12 @@ func TestBackgroundFlushWithThrottling_WhenThrottling_DelayUntilThrottleInterval
 	startTime := time.Now().Add(1 * time.Second)
 	deadline := startTime.Add(1 * time.Second)
 	assert.Error(t, lh.Flush())
-	assert.Equal(t, 100, len(lh.buffer))
+	assert.Equal(t, 100, lh.buffer.Len())
 	assert.WithinRange(t, lh.resumeAt, startTime, deadline)
 	lh.Reporter = &fakeReporter{}
-	assert.NoError(t, lh.FlushWithThrottling())
+	assert.NoError(t, lh.FlushWithThrottling(context.Background()))
 	assert.Greater(t, time.Now(), lh.resumeAt)
-	assert.Equal(t, 90, len(lh.buffer))
+	assert.Equal(t, 90, lh.buffer.Len())
 }
 
 func TestFlushTicker_WhenThrottlingEnabled_AndReceives406Error_ThrottlesRequestsUntilNextSleepDuration(t *testing.T) {
@@ -146,7 +159,7 @@ func TestFlushTicker_WhenThrottlingEnabled_AndReceives406Error_ThrottlesRequests
 		Reporter:               &fakeReporter{},
 		MaxBufferSize:          100,
 		BatchSize:              10,
-		buffer:                 make(chan string, 100),
+		buffer:                 newRingBuffer(100),
 		throttleOnBackpressure: true,
 		throttledSleepDuration: throttledSleepDuration,
 	}
@@ -174,23 +187,23 @@ func TestFlush(t *testing.T) {
 
 	addLines(lh, 100, 100, t)
 	assert.NoError(t, lh.Flush())
-	assert.Equal(t, 90, len(lh.buffer), "error flushing lines")
+	assert.Equal(t, 90, lh.buffer.Len(), "error flushing lines")
 
 	e := fmt.Errorf("error reporting points")
 	lh.Reporter = &fakeReporter{error: e}
 	assert.Error(t, lh.Flush())
-	assert.Equal(t, 90, len(lh.buffer), "error flushing lines")
+	assert.Equal(t, 90, lh.buffer.Len(), "error flushing lines")
 
 	lh.Reporter = &fakeReporter{}
-	lh.buffer = make(chan string, 100)
+	lh.buffer = newRingBuffer(100)
 	addLines(lh, 5, 5, t)
 	assert.NoError(t, lh.Flush())
-	assert.Equal(t, 0, len(lh.buffer), "error flushing lines")
+	assert.Equal(t, 0, lh.buffer.Len(), "error flushing lines")
 }
 
-func checkLength(buffer chan string, length int, msg string, t *testing.T) {
-	if len(buffer) != length {
-		t.Errorf("%s. expected: %d actual: %d", msg, length, len(buffer))
+func checkLength(buffer *ringBuffer, length int, msg string, t *testing.T) {
+	if buffer.Len() != length {
+		t.Errorf("%s. expected: %d actual: %d", msg, length, buffer.Len())
 	}
 }
 
@@ -201,8 +214,8 @@ func addLines(lh *RealLineHandler, linesToAdd int, expectedLen int, t *testing.T
 			t.Error(err)
 		}
 	}
-	if len(lh.buffer) != expectedLen {
-		t.Errorf("error adding lines. expected: %d actual: %d", expectedLen, len(lh.buffer))
+	if lh.buffer.Len() != expectedLen {
+		t.Errorf("error adding lines. expected: %d actual: %d", expectedLen, lh.buffer.Len())
 	}
 }
 
@@ -219,6 +232,395 @@ func makeLineHandler(bufSize, batchSize int) *RealLineHandler {
 		Reporter:      &fakeReporter{},
 		MaxBufferSize: bufSize,
 		BatchSize:     batchSize,
-		buffer:        make(chan string, bufSize),
+		buffer:        newRingBuffer(bufSize),
+		tracker:       &countingTracker{},
+	}
+}
+
+func TestHandleLine_WithPersistentBuffer_SpillsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	reporter := &fakeReporter{}
+	tracker := &countingTracker{}
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 5,
+		SetHandlerPrefix("points"),
+		SetPersistentBuffer(dir, 1<<20),
+		SetOverflowPolicy(OverflowSpillToDisk, 0),
+		SetSuccessTracker(tracker))
+	assert.NoDirExists(t, filepath.Join(dir, "points"))
+	lh.Start()
+	assert.DirExists(t, filepath.Join(dir, "points"))
+
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, lh.HandleLine(fmt.Sprintf("line-%d\n", i)))
+	}
+	assert.Equal(t, 5, lh.buffer.Len())
+	assert.Equal(t, 3, lh.persistentBuffer.Len())
+	assert.Equal(t, int64(0), lh.GetFailureCount())
+	assert.Equal(t, 3, tracker.spilled)
+
+	reporter.SetHTTPStatus(500)
+	lh.Stop()
+	assert.DirExists(t, filepath.Join(dir, "points"))
+
+	reporter = &fakeReporter{}
+	lh = NewLineHandler(reporter, metricFormat, time.Hour, 10, 5,
+		SetHandlerPrefix("points"),
+		SetPersistentBuffer(dir, 1<<20))
+	lh.Start()
+	assert.Equal(t, 5, lh.buffer.Len())
+	assert.Equal(t, 3, lh.persistentBuffer.Len())
+
+	lh.Stop()
+	assert.Equal(t, 2, reporter.ReportCallCount())
+	reported := strings.Join(reporter.lines, "")
+	for i := 0; i < 8; i++ {
+		assert.Contains(t, reported, fmt.Sprintf("line-%d\n", i))
+	}
+}
+
+func TestFlush_WithRetryPolicy_RetriesBatchWithBackoff(t *testing.T) {
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	lh.retryPolicy = &RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour, MaxAttempts: 3}
+	reporter := lh.Reporter.(*fakeReporter)
+
+	addLines(lh, 15, 15, t)
+	reporter.SetHTTPStatus(500)
+	assert.Error(t, lh.Flush())
+	assert.Len(t, lh.retryBatch, 10)
+	assert.Equal(t, 5, lh.buffer.Len())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), lh.retryAt, time.Minute)
+
+	assert.NoError(t, lh.FlushWithThrottling(context.Background()))
+	assert.Equal(t, 1, reporter.ReportCallCount(), "background flush should back off")
+
+	reporter.SetHTTPStatus(0)
+	assert.NoError(t, lh.Flush())
+	assert.Nil(t, lh.retryBatch)
+	assert.Equal(t, 5, lh.buffer.Len())
+	assert.Equal(t, "dummyLine", reporter.lines[0][:9])
+}
+
+func TestFlush_WithRetryPolicy_DropsBatchAfterMaxAttempts(t *testing.T) {
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	lh.retryPolicy = &RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxAttempts: 2}
+	lh.Reporter.(*fakeReporter).SetHTTPStatus(503)
+
+	addLines(lh, 10, 10, t)
+	assert.Error(t, lh.Flush())
+	assert.Len(t, lh.retryBatch, 10)
+	assert.Error(t, lh.Flush())
+	assert.Nil(t, lh.retryBatch)
+	assert.Equal(t, 0, lh.buffer.Len())
+	assert.Equal(t, 2, lh.Reporter.(*fakeReporter).ReportCallCount())
+}
+
+func TestFlush_WithRetryPolicy_CountsAttemptsPerBatch(t *testing.T) {
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	lh.retryPolicy = &RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxAttempts: 2}
+	reporter := lh.Reporter.(*fakeReporter)
+
+	addLines(lh, 10, 10, t)
+	reporter.SetHTTPStatus(503)
+	assert.Error(t, lh.Flush())
+	assert.Equal(t, 1, lh.retryAttempts)
+
+	// another flush worker reporting a batch successfully
+	reporter.SetHTTPStatus(0)
+	assert.NoError(t, lh.report(context.Background(), []string{"other\n"}, 0))
+	assert.Equal(t, 1, lh.retryAttempts, "attempts are counted per batch")
+
+	reporter.SetHTTPStatus(503)
+	assert.Error(t, lh.Flush())
+	assert.Nil(t, lh.retryBatch, "the batch should be dropped after its max attempts")
+}
+
+func TestFlush_HonorsRetryAfter(t *testing.T) {
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	reporter := lh.Reporter.(*fakeReporter)
+	reporter.header = http.Header{"Retry-After": []string{"3600"}}
+	reporter.SetHTTPStatus(429)
+
+	addLines(lh, 10, 10, t)
+	assert.Error(t, lh.Flush())
+	assert.Equal(t, 10, lh.buffer.Len())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), lh.retryAt, time.Minute)
+
+	assert.NoError(t, lh.FlushWithThrottling(context.Background()))
+	assert.Equal(t, 1, reporter.ReportCallCount())
+}
+
+func TestFlushContext_CanceledKeepsLines(t *testing.T) {
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	lh.retryPolicy = &RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour, MaxAttempts: 1}
+	reporter := lh.Reporter.(*fakeReporter)
+	addLines(lh, 15, 15, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := lh.FlushContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, lh.retryBatch, 10)
+	assert.Equal(t, 0, lh.retryAttempts)
+	assert.True(t, lh.retryAt.IsZero(), "a canceled flush should not back off")
+
+	assert.NoError(t, lh.FlushContext(context.Background()))
+	assert.Nil(t, lh.retryBatch)
+	assert.Equal(t, 2, reporter.ReportCallCount())
+}
+
+func TestDrain(t *testing.T) {
+	reporter := &fakeReporter{}
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100)
+	lh.Start()
+	addLines(lh, 25, 25, t)
+
+	result := lh.Drain(context.Background())
+	assert.NoError(t, result.Err)
+	assert.Equal(t, DrainResult{Delivered: 25}, result)
+	assert.Equal(t, 3, reporter.ReportCallCount())
+}
+
+func TestDrain_Twice(t *testing.T) {
+	reporter := &fakeReporter{}
+	reporter.SetHTTPStatus(500)
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100)
+	lh.Start()
+	addLines(lh, 25, 25, t)
+
+	result := lh.Drain(context.Background())
+	assert.Equal(t, 25, result.Lost)
+	assert.Equal(t, result, lh.Drain(context.Background()), "the first drain is returned again")
+	assert.Equal(t, 1, reporter.ReportCallCount(), "lost lines are not reported again")
+}
+
+func TestDrain_CountsLostAndPersistedLines(t *testing.T) {
+	reporter := &fakeReporter{}
+	reporter.SetHTTPStatus(500)
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100)
+	addLines(lh, 25, 25, t)
+
+	result := lh.Drain(context.Background())
+	assert.Error(t, result.Err)
+	assert.Equal(t, 0, result.Delivered)
+	assert.Equal(t, 25, result.Lost)
+
+	lh = NewLineHandler(reporter, metricFormat, time.Hour, 10, 100, SetPersistentBuffer(t.TempDir(), 1<<20))
+	lh.Start()
+	addLines(lh, 25, 25, t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = lh.Drain(ctx)
+	assert.ErrorIs(t, result.Err, context.Canceled)
+	assert.Equal(t, DrainResult{Persisted: 25, Err: result.Err}, result)
+}
+
+func TestDrain_CountsBatchesGivenUpOn(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxAttempts: 1}
+	reporter := &fakeReporter{}
+	reporter.SetHTTPStatus(500)
+	tracker := &countingTracker{}
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100, SetRetryPolicy(policy), SetSuccessTracker(tracker))
+	addLines(lh, 25, 25, t)
+
+	result := lh.Drain(context.Background())
+	assert.Error(t, result.Err)
+	assert.Equal(t, 25, result.Lost)
+	assert.Equal(t, 10, tracker.dropped)
+	assert.Equal(t, int64(11), lh.GetFailureCount(), "a failed report and its 10 dropped lines")
+
+	lh = NewLineHandler(reporter, metricFormat, time.Hour, 10, 100, SetRetryPolicy(policy),
+		SetPersistentBuffer(t.TempDir(), 1<<20))
+	lh.Start()
+	addLines(lh, 25, 25, t)
+	result = lh.Drain(context.Background())
+	assert.Error(t, result.Err)
+	assert.Equal(t, 25, result.Persisted)
+	assert.Equal(t, 0, result.Lost)
+}
+
+func TestFlushAll_AfterDrainWithPersistentBuffer(t *testing.T) {
+	reporter := &fakeReporter{}
+	reporter.SetHTTPStatus(500)
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100, SetPersistentBuffer(t.TempDir(), 1<<20))
+	lh.Start()
+	addLines(lh, 5, 5, t)
+	assert.Equal(t, 5, lh.Drain(context.Background()).Persisted)
+
+	done := make(chan error)
+	go func() { done <- lh.FlushAll() }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("FlushAll did not return once the persistent buffer was closed")
+	}
+}
+
+func TestFlush_WithMaxBatchBytes(t *testing.T) {
+	reporter := &fakeReporter{}
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100, SetMaxBatchBytes(10))
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddddddddddd\n", "e\n"} {
+		require.NoError(t, lh.HandleLine(line))
+	}
+
+	require.NoError(t, lh.Flush())
+	assert.Equal(t, []string{"aaaa\nbbbb\n"}, reporter.lines)
+
+	require.NoError(t, lh.FlushAll())
+	assert.Equal(t, []string{"aaaa\nbbbb\n", "cccc\n", "dddddddddddd\n", "e\n"}, reporter.lines)
+	assert.Equal(t, 0, lh.buffered())
+}
+
+func TestNewLineHandler_OptionsOverrideSettings(t *testing.T) {
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Second, 10, 100,
+		SetBatchSize(5),
+		SetMaxBufferSize(20),
+		SetFlushInterval(time.Minute))
+	assert.Equal(t, 5, lh.BatchSize)
+	assert.Equal(t, 20, lh.buffer.Cap())
+	assert.Equal(t, time.Minute, lh.flusher.(*backgroundFlusher).interval)
+}
+
+// gatedReporter holds every report until release is closed.
+type gatedReporter struct {
+	// keep these fields as first elements of struct
+	// to guarantee 64-bit alignment on 32-bit machines.
+	inFlight int64
+	lines    int64
+
+	fakeReporter
+	release chan struct{}
+}
+
+func (reporter *gatedReporter) ReportContext(ctx context.Context, format string, lines string) (*http.Response, error) {
+	atomic.AddInt64(&reporter.inFlight, 1)
+	defer atomic.AddInt64(&reporter.inFlight, -1)
+	select {
+	case <-reporter.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
+	status := atomic.LoadInt64(&reporter.httpResponseStatus)
+	if status != 0 {
+		return &http.Response{StatusCode: int(status)}, nil
+	}
+	atomic.AddInt64(&reporter.lines, int64(strings.Count(lines, "\n")))
+	return &http.Response{StatusCode: 200}, nil
+}
+
+func TestFlushWorkers_ReportBatchesConcurrently(t *testing.T) {
+	reporter := &gatedReporter{release: make(chan struct{})}
+	lh := NewLineHandler(reporter, metricFormat, 20*time.Millisecond, 10, 100, SetFlushWorkers(4))
+	for i := 0; i < 40; i++ {
+		require.NoError(t, lh.HandleLine("line\n"))
+	}
+	lh.Start()
+	defer lh.Stop()
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&reporter.inFlight) == 4
+	}, time.Second, 5*time.Millisecond)
+	close(reporter.release)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&reporter.lines) == 40
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFlushWorkers_WithRetryPolicy_KeepsEveryFailedBatch(t *testing.T) {
+	reporter := &gatedReporter{release: make(chan struct{})}
+	reporter.SetHTTPStatus(500)
+	lh := makeLineHandler(100, 10) // cap: 100, batchSize: 10
+	lh.Reporter = reporter
+	lh.retryPolicy = &RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	addLines(lh, 25, 25, t)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- lh.Flush() }()
+	}
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&reporter.inFlight) == 2
+	}, time.Second, 5*time.Millisecond)
+	close(reporter.release)
+	assert.Error(t, <-errs)
+	assert.Error(t, <-errs)
+
+	assert.Len(t, lh.retryBatch, 10)
+	assert.Len(t, lh.held, 10)
+	assert.Equal(t, 5, lh.buffer.Len())
+}
+
+func TestFlushWithThrottling_AdaptiveFlushDrainsToLowWaterMark(t *testing.T) {
+	reporter := &fakeReporter{}
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100, SetAdaptiveFlush(15, time.Minute))
+	addLines(lh, 100, 100, t)
+
+	assert.NoError(t, lh.FlushWithThrottling(context.Background()))
+	assert.Equal(t, 9, reporter.ReportCallCount())
+	assert.Equal(t, 10, lh.buffered())
+	lh.endTick()
+	assert.Equal(t, int64(9), atomic.LoadInt64(&lh.lastTickBatches))
+
+	reporter.SetHTTPStatus(500)
+	addLines(lh, 40, 50, t)
+	assert.Error(t, lh.FlushWithThrottling(context.Background()))
+	assert.Equal(t, 10, reporter.ReportCallCount(), "a failed batch ends the flush")
+}
+
+func TestFlushWithThrottling_AdaptiveFlushStopsAtBudget(t *testing.T) {
+	reporter := &gatedReporter{release: make(chan struct{})}
+	close(reporter.release)
+	lh := NewLineHandler(reporter, metricFormat, time.Hour, 10, 100, SetAdaptiveFlush(0, time.Nanosecond))
+	for i := 0; i < 100; i++ {
+		require.NoError(t, lh.HandleLine("line\n"))
+	}
+
+	assert.NoError(t, lh.FlushWithThrottling(context.Background()))
+	assert.Equal(t, int64(10), atomic.LoadInt64(&reporter.lines))
+	assert.Equal(t, 90, lh.buffered())
+}
+
+// discardReporter accepts every report without sending it anywhere.
+type discardReporter struct{}
+
+func (discardReporter) Report(string, string) (*http.Response, error) {
+	return &http.Response{StatusCode: 200}, nil
+}
+
+func (discardReporter) ReportContext(context.Context, string, string) (*http.Response, error) {
+	return &http.Response{StatusCode: 200}, nil
+}
+
+func BenchmarkHandleLineAndFlush(b *testing.B) {
+	lh := NewLineHandler(discardReporter{}, metricFormat, time.Hour, 1000, 10000)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_ = lh.HandleLine("\"foo.metric\" 1.2 1533529977 source=\"test_source\" \"env\"=\"test\"\n")
+		if n%1000 == 999 {
+			_ = lh.Flush()
+		}
+	}
+}
+
+func TestHandleLines(t *testing.T) {
+	tracker := &countingTracker{}
+	lh := NewLineHandler(&fakeReporter{}, metricFormat, time.Hour, 10, 4,
+		SetRateLimit(1, 3, RateLimitDrop),
+		SetSuccessTracker(tracker))
+	lh.rateLimiter.now = func() time.Time { return lh.rateLimiter.last }
+
+	assert.Nil(t, lh.HandleLines(context.Background(), []string{"a\n", "b\n"}))
+	errs := lh.HandleLines(context.Background(), []string{"c\n", "d\n"})
+	require.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1], "the rate limit is taken from once for every line")
+	assert.Equal(t, 1, tracker.limited)
+	assert.Equal(t, 3, lh.buffer.Len())
+
+	lh.rateLimiter = nil
+	errs = lh.HandleLines(context.Background(), []string{"e\n", "f\n"})
+	require.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1], "the buffer is full")
+	assert.Equal(t, int64(2), lh.GetFailureCount())
 }
@@ -1,11 +1,12 @@
 package internal
 
 import (
-	"bytes"
 	"compress/gzip"
+	"context"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/wavefronthq/wavefront-sdk-go/internal/auth"
 )
@@ -15,63 +16,142 @@ type reporter struct {
 	serverURL    string
 	tokenService auth.Service
 	client       *http.Client
+	codec        Codec
+}
+
+type ReporterOption func(*reporter)
+
+// SetCodec sets how the reports are compressed, gzip at the default level by default.
+func SetCodec(codec Codec) ReporterOption {
+	return func(r *reporter) {
+		r.codec = codec
+	}
 }
 
 // NewReporter creates a metrics Reporter
-func NewReporter(server string, tokenService auth.Service, client *http.Client) Reporter {
-	return &reporter{
+func NewReporter(server string, tokenService auth.Service, client *http.Client, setters ...ReporterOption) Reporter {
+	r := &reporter{
 		serverURL:    server,
 		tokenService: tokenService,
 		client:       client,
 	}
+	for _, setter := range setters {
+		setter(r)
+	}
+	if r.codec == nil {
+		r.codec, _ = NewGzipCodec(gzip.DefaultCompression)
+	}
+	return r
 }
 
 // Report creates and sends a POST to the reportEndpoint with the given pointLines
 func (reporter reporter) Report(format string, pointLines string) (*http.Response, error) {
+	return reporter.ReportContext(context.Background(), format, pointLines)
+}
+
+// ReportContext is like Report, with ctx bounding the request.
+func (reporter reporter) ReportContext(ctx context.Context, format string, pointLines string) (*http.Response, error) {
 	if format == "" || pointLines == "" {
 		return nil, formatError
 	}
 
 	if format == eventFormat {
-		return reporter.reportEvent(pointLines)
+		return reporter.reportEvent(ctx, pointLines)
 	}
+	return reporter.ReportLines(ctx, format, []string{pointLines})
+}
 
-	requestBody, err := linesToGzippedBytes(pointLines)
-	if err != nil {
-		return nil, err
+// ReportLines is like ReportContext, streaming lines through the codec of the reporter
+// into the request body as they are sent, instead of joining and compressing them first.
+// The lines are encoded again if the request is retried on a new connection.
+func (reporter reporter) ReportLines(ctx context.Context, format string, lines []string) (*http.Response, error) {
+	if format == "" || len(lines) == 0 {
+		return nil, formatError
+	}
+
+	if format == eventFormat {
+		return reporter.reportEvent(ctx, strings.Join(lines, ""))
 	}
 
-	req, err := reporter.buildRequest(format, requestBody)
+	bodies := &encodedLines{codec: reporter.codec, lines: lines}
+	defer bodies.close()
+	body, _ := bodies.open()
+	req, err := reporter.buildRequest(ctx, format, body)
 	if err != nil {
 		return nil, err
 	}
+	// lets net/http retry the request on a new connection when a reused one turns out to be closed.
+	req.GetBody = bodies.open
 
 	return reporter.execute(req)
 }
 
-func linesToGzippedBytes(pointLines string) ([]byte, error) {
-	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	_, err := zw.Write([]byte(pointLines))
-	if err != nil {
-		_ = zw.Close()
-		return nil, err
+// encodedLines streams lines encoded by codec into request bodies, as many times as needed.
+type encodedLines struct {
+	codec Codec
+	lines []string
+
+	mtx     sync.Mutex
+	bodies  []*io.PipeReader
+	encoded sync.WaitGroup
+}
+
+// open returns a body streaming the encoded lines.
+func (e *encodedLines) open() (io.ReadCloser, error) {
+	body, w := io.Pipe()
+	e.mtx.Lock()
+	e.bodies = append(e.bodies, body)
+	e.mtx.Unlock()
+	e.encoded.Add(1)
+	go func() {
+		defer e.encoded.Done()
+		_ = w.CloseWithError(encodeLines(e.codec.NewWriter(w), e.lines))
+	}()
+	return body, nil
+}
+
+// close unblocks the encoding of the lines the requests did not read, and waits for it to end.
+func (e *encodedLines) close() {
+	e.mtx.Lock()
+	for _, body := range e.bodies {
+		_ = body.Close()
 	}
-	if err = zw.Close(); err != nil {
-		return nil, err
+	e.mtx.Unlock()
+	e.encoded.Wait()
+}
+
+// encodeChunkSize is the number of bytes of lines gathered before they are handed to the codec.
+const encodeChunkSize = 32 * 1024
+
+// encodeLines writes lines to w in chunks, and closes w.
+func encodeLines(w io.WriteCloser, lines []string) error {
+	chunk := GetBuffer()
+	defer PutBuffer(chunk)
+	for i, line := range lines {
+		chunk.WriteString(line)
+		if chunk.Len() < encodeChunkSize && i < len(lines)-1 {
+			continue
+		}
+		if _, err := w.Write(chunk.Bytes()); err != nil {
+			_ = w.Close()
+			return err
+		}
+		chunk.Reset()
 	}
-	return buf.Bytes(), err
+	return w.Close()
 }
 
-func (reporter reporter) buildRequest(format string, body []byte) (*http.Request, error) {
+func (reporter reporter) buildRequest(ctx context.Context, format string, body io.Reader) (*http.Request, error) {
 	apiURL := reporter.serverURL + reportEndpoint
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, body)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set(contentType, octetStream)
-	req.Header.Set(contentEncoding, gzipFormat)
+	if encoding := reporter.codec.ContentEncoding(); encoding != "" {
+		req.Header.Set(contentEncoding, encoding)
+	}
 
 	err = reporter.tokenService.Authorize(req)
 	if err != nil {
@@ -84,13 +164,13 @@ func (reporter reporter) buildRequest(format string, body []byte) (*http.Request
 	return req, nil
 }
 
-func (reporter reporter) reportEvent(event string) (*http.Response, error) {
+func (reporter reporter) reportEvent(ctx context.Context, event string) (*http.Response, error) {
 	if event == "" {
 		return nil, formatError
 	}
 
 	apiURL := reporter.serverURL + eventEndpoint
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(event))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(event))
 	if err != nil {
 		return nil, err
 	}
@@ -1,7 +1,15 @@
 package internal
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,7 +19,130 @@ import (
 
 func TestReporter_BuildRequest(t *testing.T) {
 	r := NewReporter("http://localhost:8010/wavefront", auth.NewNoopTokenService(), &http.Client{}).(*reporter)
-	request, err := r.buildRequest("wavefront", nil)
+	request, err := r.buildRequest(context.Background(), "wavefront", nil)
 	require.NoError(t, err)
 	assert.Equal(t, "http://localhost:8010/wavefront/report?f=wavefront", request.URL.String())
 }
+
+func TestReporter_ReportContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	r := NewReporter(server.URL, auth.NewNoopTokenService(), server.Client())
+
+	resp, err := r.ReportContext(context.Background(), metricFormat, "line\n")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.ReportContext(ctx, metricFormat, "line\n")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = r.ReportContext(ctx, eventFormat, "event")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReporter_Codecs(t *testing.T) {
+	lines := make([]string, 5000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("\"metric.%d\" %d source=\"localhost\"\n", i, i)
+	}
+	deflate, err := NewDeflateCodec(zlib.BestSpeed)
+	require.NoError(t, err)
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		"":        func(r io.Reader) (io.Reader, error) { return r, nil },
+	}
+
+	for _, codec := range []Codec{nil, deflate, NoCompression} {
+		var encoding, body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding = r.Header.Get(contentEncoding)
+			decoded, err := decoders[encoding](r.Body)
+			require.NoError(t, err)
+			raw, err := io.ReadAll(decoded)
+			require.NoError(t, err)
+			body = string(raw)
+		}))
+		var options []ReporterOption
+		if codec != nil {
+			options = append(options, SetCodec(codec))
+		}
+		r := NewReporter(server.URL, auth.NewNoopTokenService(), server.Client(), options...).(*reporter)
+
+		for i := 0; i < 2; i++ {
+			resp, err := r.ReportLines(context.Background(), metricFormat, lines)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, r.codec.ContentEncoding(), encoding)
+			assert.Equal(t, strings.Join(lines, ""), body)
+		}
+		server.Close()
+	}
+
+	_, err = NewGzipCodec(42)
+	assert.Error(t, err)
+}
+
+// roundTripperFunc is an http.RoundTripper calling itself.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestReporter_ReportLinesCanBeRetried(t *testing.T) {
+	lines := []string{"line-1\n", "line-2\n"}
+	var bodies []string
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// reads part of the body as a failed attempt would, then the whole body of a retry.
+		_, err := req.Body.Read(make([]byte, 1))
+		require.NoError(t, err)
+		require.NotNil(t, req.GetBody)
+		for i := 0; i < 2; i++ {
+			body, err := req.GetBody()
+			require.NoError(t, err)
+			raw, err := io.ReadAll(body)
+			require.NoError(t, err)
+			bodies = append(bodies, string(raw))
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	r := NewReporter("http://localhost:8010", auth.NewNoopTokenService(), client, SetCodec(NoCompression)).(*reporter)
+
+	resp, err := r.ReportLines(context.Background(), metricFormat, lines)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"line-1\nline-2\n", "line-1\nline-2\n"}, bodies)
+}
+
+func benchmarkLines() []string {
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("\"foo.metric.%d\" 1.2 1533529977 source=\"test_source\" \"env\"=\"test\"\n", i)
+	}
+	return lines
+}
+
+// BenchmarkJoinAndGzip encodes a batch the way reports used to be encoded, as a baseline.
+func BenchmarkJoinAndGzip(b *testing.B) {
+	lines := benchmarkLines()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(strings.Join(lines, "")))
+		_ = zw.Close()
+	}
+}
+
+func BenchmarkEncodeLines(b *testing.B) {
+	lines := benchmarkLines()
+	codec, _ := NewGzipCodec(gzip.DefaultCompression)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_ = encodeLines(codec.NewWriter(io.Discard), lines)
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const retryAfterHeader = "Retry-After"
+
+// RetryPolicy controls how a RealLineHandler retries a batch that could not be reported.
+type RetryPolicy struct {
+	// InitialBackoff is the delay before the first retry. It doubles on every retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of each delay that is randomized.
+	Jitter float64
+
+	// MaxAttempts is the number of times a batch is reported before it is dropped. 0 means no limit.
+	MaxAttempts int
+}
+
+// backoff returns the delay to wait after the given number of failed attempts.
+func (p *RetryPolicy) backoff(attempts int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+func (p *RetryPolicy) exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// retryAfter returns the delay requested by the server through the Retry-After
+// header of a 429 or 503 response.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	value := resp.Header.Get(retryAfterHeader)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if date.Before(now) {
+			return 0, true
+		}
+		return date.Sub(now), true
+	}
+	return 0, false
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	assert.Equal(t, 1*time.Second, policy.backoff(1))
+	assert.Equal(t, 2*time.Second, policy.backoff(2))
+	assert.Equal(t, 4*time.Second, policy.backoff(3))
+	assert.Equal(t, 8*time.Second, policy.backoff(4))
+	assert.Equal(t, 10*time.Second, policy.backoff(5))
+	assert.Equal(t, 10*time.Second, policy.backoff(100))
+}
+
+func TestRetryPolicy_Jitter(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(1)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+func TestRetryPolicy_Exhausted(t *testing.T) {
+	assert.False(t, (&RetryPolicy{}).exhausted(1000))
+	assert.False(t, (&RetryPolicy{MaxAttempts: 3}).exhausted(2))
+	assert.True(t, (&RetryPolicy{MaxAttempts: 3}).exhausted(3))
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	response := func(status int, value string) *http.Response {
+		return &http.Response{StatusCode: status, Header: http.Header{retryAfterHeader: []string{value}}}
+	}
+
+	delay, ok := retryAfter(response(429, "120"), now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, delay)
+
+	delay, ok = retryAfter(response(503, now.Add(30*time.Second).Format(http.TimeFormat)), now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, delay)
+
+	_, ok = retryAfter(response(500, "120"), now)
+	assert.False(t, ok)
+	_, ok = retryAfter(response(429, "soon"), now)
+	assert.False(t, ok)
+	_, ok = retryAfter(&http.Response{StatusCode: 429}, now)
+	assert.False(t, ok)
+	_, ok = retryAfter(nil, now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"sync/atomic"
+)
+
+// ringBuffer is a bounded, lock-free queue of lines, based on Dmitry Vyukov's bounded
+// MPMC queue. Any number of goroutines can offer lines concurrently, and lines are polled
+// by the flushers of the handler as well as by producers evicting the oldest line.
+//
+// Like a nil channel used in a select with a default case, a nil ringBuffer is both full
+// and empty. A closed ringBuffer is full, but its lines can still be polled.
+type ringBuffer struct {
+	head   atomic.Uint64 // position of the next line to poll
+	_      [56]byte      // keeps head and tail on separate cache lines
+	tail   atomic.Uint64 // position of the next line to offer
+	_      [56]byte
+	slots  []ringSlot
+	size   int // max number of lines, lower than len(slots) only for a buffer of a single line
+	space  chan struct{}
+	closed atomic.Bool
+}
+
+type ringSlot struct {
+	// seq is the position the slot is ready for: pos when free to offer the line at pos,
+	// pos+1 once that line can be polled.
+	seq  atomic.Uint64
+	line string
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	slots := capacity
+	if slots == 1 {
+		// a slot is told free from ready to poll by its sequence, which requires at least 2 slots.
+		slots = 2
+	}
+	r := &ringBuffer{
+		slots: make([]ringSlot, slots),
+		size:  capacity,
+		space: make(chan struct{}, 1),
+	}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// offer adds line to the buffer, and reports whether there was room for it.
+func (r *ringBuffer) offer(line string) bool {
+	if !r.open() {
+		return false
+	}
+	size := uint64(len(r.slots))
+	pos := r.tail.Load()
+	for {
+		slot := &r.slots[pos%size]
+		seq := slot.seq.Load()
+		switch diff := int64(seq - pos); {
+		case diff == 0:
+			if r.size < len(r.slots) && pos-r.head.Load() >= uint64(r.size) {
+				return false
+			}
+			if r.tail.CompareAndSwap(pos, pos+1) {
+				slot.line = line
+				slot.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.tail.Load()
+		case diff < 0:
+			return false
+		default:
+			pos = r.tail.Load()
+		}
+	}
+}
+
+// poll takes the oldest line off the buffer.
+func (r *ringBuffer) poll() (string, bool) {
+	if r == nil || r.size == 0 {
+		return "", false
+	}
+	size := uint64(len(r.slots))
+	pos := r.head.Load()
+	for {
+		slot := &r.slots[pos%size]
+		seq := slot.seq.Load()
+		switch diff := int64(seq - (pos + 1)); {
+		case diff == 0:
+			if r.head.CompareAndSwap(pos, pos+1) {
+				line := slot.line
+				slot.line = ""
+				slot.seq.Store(pos + size)
+				r.signal()
+				return line, true
+			}
+			pos = r.head.Load()
+		case diff < 0:
+			return "", false
+		default:
+			pos = r.head.Load()
+		}
+	}
+}
+
+// close makes the buffer refuse lines from now on.
+func (r *ringBuffer) close() {
+	if r != nil {
+		r.closed.Store(true)
+	}
+}
+
+// open reports whether lines can ever be offered to the buffer.
+func (r *ringBuffer) open() bool {
+	return r != nil && r.size > 0 && !r.closed.Load()
+}
+
+// signal wakes up a producer waiting for room in the buffer, if any.
+func (r *ringBuffer) signal() {
+	select {
+	case r.space <- struct{}{}:
+	default:
+	}
+}
+
+// waitForSpace returns a channel receiving a value after a line is polled. A producer that
+// was woken up and took the room must signal in turn if room is left for other producers.
+func (r *ringBuffer) waitForSpace() <-chan struct{} {
+	if r == nil {
+		return nil
+	}
+	return r.space
+}
+
+// Len returns the number of lines in the buffer.
+func (r *ringBuffer) Len() int {
+	if r == nil {
+		return 0
+	}
+	head := r.head.Load()
+	tail := r.tail.Load()
+	if tail <= head {
+		return 0
+	}
+	if n := int(tail - head); n < r.size {
+		return n
+	}
+	return r.size
+}
+
+// Cap returns the max number of lines in the buffer.
+func (r *ringBuffer) Cap() int {
+	if r == nil {
+		return 0
+	}
+	return r.size
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poll takes the oldest line off the buffer of lh.
+func poll(t *testing.T, lh *RealLineHandler) string {
+	line, ok := lh.buffer.poll()
+	require.True(t, ok, "empty buffer")
+	return line
+}
+
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(3)
+	for i := 0; i < 3; i++ {
+		assert.True(t, r.offer(fmt.Sprint(i)))
+	}
+	assert.False(t, r.offer("3"), "full")
+	assert.Equal(t, 3, r.Len())
+
+	for round := 0; round < 5; round++ {
+		line, ok := r.poll()
+		assert.True(t, ok)
+		assert.Equal(t, fmt.Sprint(round), line)
+		assert.True(t, r.offer(fmt.Sprint(round+3)))
+		assert.Equal(t, 3, r.Len())
+	}
+	for i := 5; i < 8; i++ {
+		line, _ := r.poll()
+		assert.Equal(t, fmt.Sprint(i), line)
+	}
+	_, ok := r.poll()
+	assert.False(t, ok, "empty")
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestRingBuffer_Nil(t *testing.T) {
+	var r *ringBuffer
+	assert.False(t, r.offer("line"))
+	_, ok := r.poll()
+	assert.False(t, ok)
+	assert.Equal(t, 0, r.Len())
+	assert.False(t, newRingBuffer(0).offer("line"))
+}
+
+func TestRingBuffer_ConcurrentProducers(t *testing.T) {
+	const producers, lines = 8, 1000
+	r := newRingBuffer(64)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				for !r.offer(fmt.Sprintf("%d-%d", p, i)) {
+					runtime.Gosched()
+				}
+			}
+		}(p)
+	}
+
+	next := make([]int, producers)
+	for received := 0; received < producers*lines; {
+		line, ok := r.poll()
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		received++
+		var p, i int
+		_, err := fmt.Sscanf(line, "%d-%d", &p, &i)
+		require.NoError(t, err)
+		require.Equal(t, next[p], i, "lines of a producer are polled in order")
+		next[p]++
+	}
+	wg.Wait()
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestRingBuffer_SingleLine(t *testing.T) {
+	r := newRingBuffer(1)
+	for i := 0; i < 3; i++ {
+		assert.True(t, r.offer("line"))
+		assert.False(t, r.offer("line"), "full")
+		assert.Equal(t, 1, r.Len())
+		_, ok := r.poll()
+		assert.True(t, ok)
+	}
+}
+
+func BenchmarkChannel(b *testing.B) {
+	buffer := make(chan string, 1024)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			select {
+			case buffer <- "line":
+			default:
+				<-buffer
+			}
+		}
+	})
+}
+
+func BenchmarkRingBuffer(b *testing.B) {
+	r := newRingBuffer(1024)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if !r.offer("line") {
+				r.poll()
+			}
+		}
+	})
+}
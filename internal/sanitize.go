@@ -1,67 +1,85 @@
 package internal
 
 import (
-	"regexp"
+	"bytes"
 	"strings"
 )
 
-var /* const */ quotation = regexp.MustCompile(`"`)
-var /* const */ lineBreak = regexp.MustCompile(`\n`)
-
 // Sanitize sanitizes string of metric name, source and key of tags according to the rule of Wavefront proxy.
 func Sanitize(str string) string {
 	sb := GetBuffer()
 	defer PutBuffer(sb)
+	WriteSanitized(sb, str)
+	return sb.String()
+}
 
+// WriteSanitized writes str to sb, sanitized like Sanitize does, without allocating.
+func WriteSanitized(sb *bytes.Buffer, str string) {
+	if str == "" {
+		return
+	}
 	// first character can be \u2206 (∆ - INCREMENT) or \u0394 (Δ - GREEK CAPITAL LETTER DELTA)
 	// or ~ tilda character for internal metrics
 	skipHead := 0
 	if strings.HasPrefix(str, DeltaPrefix) {
 		sb.WriteString(DeltaPrefix)
-		skipHead = 3
+		skipHead = len(DeltaPrefix)
 	}
 	if strings.HasPrefix(str, AltDeltaPrefix) {
 		sb.WriteString(AltDeltaPrefix)
-		skipHead = 2
+		skipHead = len(AltDeltaPrefix)
 	}
 	// Second character can be ~ tilda character if first character
 	// is \u2206 (∆ - INCREMENT) or \u0394 (Δ - GREEK CAPITAL LETTER)
-	if (strings.HasPrefix(str, DeltaPrefix) || strings.HasPrefix(str, AltDeltaPrefix)) &&
-		str[skipHead] == 126 {
-		sb.WriteString(string(str[skipHead]))
+	if skipHead > 0 && skipHead < len(str) && str[skipHead] == '~' {
+		sb.WriteByte('~')
 		skipHead++
 	}
-	if str[0] == 126 {
-		sb.WriteString(string(str[0]))
+	if str[0] == '~' {
+		sb.WriteByte('~')
 		skipHead = 1
 	}
 
-	for i := 0; i < len(str); i++ {
-		if skipHead > 0 {
-			i += skipHead
-			skipHead = 0
-		}
+	for i := skipHead; i < len(str); i++ {
 		cur := str[i]
-		strCur := string(cur)
-		isLegal := true
-
-		if !(44 <= cur && cur <= 57) && !(65 <= cur && cur <= 90) && !(97 <= cur && cur <= 122) && cur != 95 {
-			isLegal = false
-		}
-		if isLegal {
-			sb.WriteString(strCur)
+		if (44 <= cur && cur <= 57) || (65 <= cur && cur <= 90) || (97 <= cur && cur <= 122) || cur == 95 {
+			sb.WriteByte(cur)
 		} else {
-			sb.WriteString("-")
+			sb.WriteByte('-')
 		}
 	}
-	return sb.String()
+}
+
+// WriteQuotedSanitized writes str to sb sanitized and quoted, like strconv.Quote(Sanitize(str))
+// without allocating: a sanitized string holds no character that needs escaping.
+func WriteQuotedSanitized(sb *bytes.Buffer, str string) {
+	sb.WriteByte('"')
+	WriteSanitized(sb, str)
+	sb.WriteByte('"')
 }
 
 // SanitizeValue sanitizes string of tags value, etc.
 func SanitizeValue(str string) string {
-	res := strings.TrimSpace(str)
-	if strings.Contains(str, "\"") || strings.Contains(str, "'") {
-		res = quotation.ReplaceAllString(res, "\\\"")
+	sb := GetBuffer()
+	defer PutBuffer(sb)
+	WriteSanitizedValue(sb, str)
+	return sb.String()
+}
+
+// WriteSanitizedValue writes str to sb, sanitized like SanitizeValue does, without allocating:
+// trimmed and quoted, with its quotation marks and line breaks escaped.
+func WriteSanitizedValue(sb *bytes.Buffer, str string) {
+	str = strings.TrimSpace(str)
+	sb.WriteByte('"')
+	for i := 0; i < len(str); i++ {
+		switch cur := str[i]; cur {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		default:
+			sb.WriteByte(cur)
+		}
 	}
-	return "\"" + lineBreak.ReplaceAllString(res, "\\n") + "\""
+	sb.WriteByte('"')
 }
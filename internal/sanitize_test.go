@@ -33,3 +33,13 @@ func TestSanitizeValue(t *testing.T) {
 	assert.Equal(t, "\"hello'world\"", SanitizeValue("hello'world"))
 	assert.Equal(t, "\"hello\\nworld\"", SanitizeValue("hello\nworld"))
 }
+
+func TestWriteQuotedSanitized(t *testing.T) {
+	for _, str := range []string{"hello", "hello world", "hello\"world\\", "~component.heartbeat",
+		"Δcomponent.heartbeat", "∆~component.heartbeat", "∆", "ünïcode\tname", "a\x00b"} {
+		sb := GetBuffer()
+		WriteQuotedSanitized(sb, str)
+		assert.Equal(t, strconv.Quote(Sanitize(str)), sb.String(), str)
+		PutBuffer(sb)
+	}
+}
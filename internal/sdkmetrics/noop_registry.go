@@ -49,3 +49,15 @@ func (n noOpTracker) IncInvalid() {
 
 func (n noOpTracker) IncDropped() {
 }
+
+func (n noOpTracker) IncBlocked() {
+}
+
+func (n noOpTracker) IncEvicted() {
+}
+
+func (n noOpTracker) IncSpilled() {
+}
+
+func (n noOpTracker) IncRateLimited() {
+}
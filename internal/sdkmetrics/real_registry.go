@@ -13,6 +13,7 @@ type realRegistry struct {
 	reportTicker *time.Ticker
 	sender       internalSender
 	done         chan struct{}
+	stopOnce     sync.Once
 
 	mtx               sync.Mutex
 	metrics           map[string]interface{}
@@ -49,9 +50,13 @@ func (registry *realRegistry) EventsTracker() SuccessTracker {
 
 func (registry *realRegistry) newSuccessTracker(prefix string) *realSuccessTracker {
 	return &realSuccessTracker{
-		Valid:   registry.NewDeltaCounter(prefix + ".valid"),
-		Invalid: registry.NewDeltaCounter(prefix + ".invalid"),
-		Dropped: registry.NewDeltaCounter(prefix + ".dropped"),
+		Valid:       registry.NewDeltaCounter(prefix + ".valid"),
+		Invalid:     registry.NewDeltaCounter(prefix + ".invalid"),
+		Dropped:     registry.NewDeltaCounter(prefix + ".dropped"),
+		Blocked:     registry.NewDeltaCounter(prefix + ".blocked"),
+		Evicted:     registry.NewDeltaCounter(prefix + ".evicted"),
+		Spilled:     registry.NewDeltaCounter(prefix + ".spilled"),
+		RateLimited: registry.NewDeltaCounter(prefix + ".rate_limited"),
 	}
 }
 
@@ -91,8 +96,10 @@ func (registry *realRegistry) start() {
 }
 
 func (registry *realRegistry) Stop() {
-	registry.reportTicker.Stop()
-	registry.done <- struct{}{}
+	registry.stopOnce.Do(func() {
+		registry.reportTicker.Stop()
+		registry.done <- struct{}{}
+	})
 }
 
 // report sends internal SDK metrics and delta counters using an internalSender.
@@ -19,21 +19,41 @@ func TestRealMetricRegistry(t *testing.T) {
 	registry.Flush()
 
 	assert.Equal(t, map[string]float64{
-		"~test.events.dropped":     0.0,
-		"~test.events.invalid":     0.0,
-		"~test.events.valid":       0.0,
-		"~test.histograms.dropped": 0.0,
-		"~test.histograms.invalid": 0.0,
-		"~test.histograms.valid":   0.0,
-		"~test.points.dropped":     0.0,
-		"~test.points.invalid":     0.0,
-		"~test.points.valid":       1.0,
-		"~test.span_logs.dropped":  0.0,
-		"~test.span_logs.invalid":  1.0,
-		"~test.span_logs.valid":    0.0,
-		"~test.spans.dropped":      1.0,
-		"~test.spans.invalid":      0.0,
-		"~test.spans.valid":        0.0,
+		"~test.events.blocked":          0.0,
+		"~test.events.dropped":          0.0,
+		"~test.events.evicted":          0.0,
+		"~test.events.invalid":          0.0,
+		"~test.events.rate_limited":     0.0,
+		"~test.events.spilled":          0.0,
+		"~test.events.valid":            0.0,
+		"~test.histograms.blocked":      0.0,
+		"~test.histograms.dropped":      0.0,
+		"~test.histograms.evicted":      0.0,
+		"~test.histograms.invalid":      0.0,
+		"~test.histograms.rate_limited": 0.0,
+		"~test.histograms.spilled":      0.0,
+		"~test.histograms.valid":        0.0,
+		"~test.points.blocked":          0.0,
+		"~test.points.dropped":          0.0,
+		"~test.points.evicted":          0.0,
+		"~test.points.invalid":          0.0,
+		"~test.points.rate_limited":     0.0,
+		"~test.points.spilled":          0.0,
+		"~test.points.valid":            1.0,
+		"~test.span_logs.blocked":       0.0,
+		"~test.span_logs.dropped":       0.0,
+		"~test.span_logs.evicted":       0.0,
+		"~test.span_logs.invalid":       1.0,
+		"~test.span_logs.rate_limited":  0.0,
+		"~test.span_logs.spilled":       0.0,
+		"~test.span_logs.valid":         0.0,
+		"~test.spans.blocked":           0.0,
+		"~test.spans.dropped":           1.0,
+		"~test.spans.evicted":           0.0,
+		"~test.spans.invalid":           0.0,
+		"~test.spans.rate_limited":      0.0,
+		"~test.spans.spilled":           0.0,
+		"~test.spans.valid":             0.0,
 	}, sender.deltaCounters)
 }
 
@@ -1,15 +1,28 @@
 package sdkmetrics
 
+// SuccessTracker counts what happens to the data sent for a data type.
 type SuccessTracker interface {
 	IncValid()
 	IncInvalid()
 	IncDropped()
+	// IncBlocked counts the calls blocked on a full buffer.
+	IncBlocked()
+	// IncEvicted counts the buffered lines dropped to make room for newer ones.
+	IncEvicted()
+	// IncSpilled counts the lines written to disk because the buffer was full.
+	IncSpilled()
+	// IncRateLimited counts the lines dropped, or held back at a flush, because of a rate limit.
+	IncRateLimited()
 }
 
 type realSuccessTracker struct {
-	Valid   *DeltaCounter
-	Invalid *DeltaCounter
-	Dropped *DeltaCounter
+	Valid       *DeltaCounter
+	Invalid     *DeltaCounter
+	Dropped     *DeltaCounter
+	Blocked     *DeltaCounter
+	Evicted     *DeltaCounter
+	Spilled     *DeltaCounter
+	RateLimited *DeltaCounter
 }
 
 func (f *realSuccessTracker) IncValid() {
@@ -23,3 +36,19 @@ func (f *realSuccessTracker) IncInvalid() {
 func (f *realSuccessTracker) IncDropped() {
 	f.Dropped.Inc()
 }
+
+func (f *realSuccessTracker) IncBlocked() {
+	f.Blocked.Inc()
+}
+
+func (f *realSuccessTracker) IncEvicted() {
+	f.Evicted.Inc()
+}
+
+func (f *realSuccessTracker) IncSpilled() {
+	f.Spilled.Inc()
+}
+
+func (f *realSuccessTracker) IncRateLimited() {
+	f.RateLimited.Inc()
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// socketConnectionHandler is a ConnectionHandler that streams lines in the
+// Wavefront plaintext format over a persistent stream socket, such as the TCP
+// listeners of a Wavefront proxy or a Unix domain socket. The connection is (re-)established lazily
+// whenever data is sent while disconnected.
+type socketConnectionHandler struct {
+	// keep this field as first element of struct
+	// to guarantee 64-bit alignment on 32-bit machines.
+	failures int64
+
+	network string
+	address string
+	timeout time.Duration
+
+	mtx    sync.Mutex
+	conn   net.Conn
+	writer *bufio.Writer
+}
+
+// NewTCPConnectionHandler creates a ConnectionHandler sending data to the TCP
+// listener at address. timeout bounds both dialing and each write.
+// The returned handler also implements Reporter, so it can be used by line handlers.
+func NewTCPConnectionHandler(address string, timeout time.Duration) ConnectionHandler {
+	return &socketConnectionHandler{
+		network: "tcp",
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// NewUnixConnectionHandler creates a ConnectionHandler sending data to the Unix
+// domain stream socket at path. timeout bounds both dialing and each write.
+// The returned handler also implements Reporter, so it can be used by line handlers.
+func NewUnixConnectionHandler(path string, timeout time.Duration) ConnectionHandler {
+	return &socketConnectionHandler{
+		network: "unix",
+		address: path,
+		timeout: timeout,
+	}
+}
+
+// Start connects eagerly. A failure is only logged, as the connection is retried on send.
+func (h *socketConnectionHandler) Start() {
+	if err := h.Connect(); err != nil {
+		log.Printf("unable to connect to %s://%s: %v\n", h.network, h.address, err)
+	}
+}
+
+func (h *socketConnectionHandler) Connect() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.connect(context.Background())
+}
+
+func (h *socketConnectionHandler) connect(ctx context.Context) error {
+	if h.conn != nil {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: h.timeout}
+	conn, err := dialer.DialContext(ctx, h.network, h.address)
+	if err != nil {
+		return err
+	}
+	h.conn = conn
+	h.writer = bufio.NewWriter(conn)
+	return nil
+}
+
+func (h *socketConnectionHandler) Connected() bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.conn != nil
+}
+
+// Close flushes pending data and closes the connection.
+func (h *socketConnectionHandler) Close() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.conn == nil {
+		return
+	}
+	if err := h.flush(); err != nil {
+		log.Printf("error flushing data to %s://%s: %v\n", h.network, h.address, err)
+	}
+	h.disconnect()
+}
+
+func (h *socketConnectionHandler) disconnect() {
+	if h.conn != nil {
+		_ = h.conn.Close()
+	}
+	h.conn = nil
+	h.writer = nil
+}
+
+// SendData writes lines to the connection, reconnecting once if the connection was lost.
+// Lines are buffered until the buffer fills up or Flush is called.
+func (h *socketConnectionHandler) SendData(lines string) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.sendLines(context.Background(), lines, h.write)
+}
+
+// sendLines writes lines with write, and again on a new connection if it fails, resending
+// only the lines that were not completely written. The line cut short by the failure is
+// resent whole, so no line is sent twice; the data still buffered when a write fails is lost.
+func (h *socketConnectionHandler) sendLines(ctx context.Context, lines string, write func(context.Context, string) (int, error)) error {
+	return h.retryOnce(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := write(ctx, lines)
+		lines = lines[strings.LastIndexByte(lines[:n], '\n')+1:]
+		return err
+	})
+}
+
+// retryOnce runs send, and runs it again on a new connection if it fails.
+func (h *socketConnectionHandler) retryOnce(send func() error) error {
+	err := send()
+	if err != nil {
+		h.disconnect()
+		err = send()
+	}
+	if err != nil {
+		h.disconnect()
+		atomic.AddInt64(&h.failures, 1)
+		return fmt.Errorf("error sending data to %s://%s: %v", h.network, h.address, err)
+	}
+	return nil
+}
+
+// write writes lines to the buffer of the connection, and returns how many bytes were written.
+func (h *socketConnectionHandler) write(ctx context.Context, lines string) (int, error) {
+	if err := h.connect(ctx); err != nil {
+		return 0, err
+	}
+	_ = h.conn.SetWriteDeadline(writeDeadline(ctx, h.timeout))
+	return h.writer.WriteString(lines)
+}
+
+// writeThrough flushes the buffer of the connection, then writes lines to the connection
+// itself, and returns how many bytes of lines were written.
+func (h *socketConnectionHandler) writeThrough(ctx context.Context, lines string) (int, error) {
+	if err := h.connect(ctx); err != nil {
+		return 0, err
+	}
+	if err := h.flush(); err != nil {
+		return 0, err
+	}
+	_ = h.conn.SetWriteDeadline(writeDeadline(ctx, h.timeout))
+	return io.WriteString(h.conn, lines)
+}
+
+// Flush writes buffered data to the connection.
+func (h *socketConnectionHandler) Flush() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if err := h.flush(); err != nil {
+		h.disconnect()
+		atomic.AddInt64(&h.failures, 1)
+		return fmt.Errorf("error sending data to %s://%s: %v", h.network, h.address, err)
+	}
+	return nil
+}
+
+func (h *socketConnectionHandler) flush() error {
+	if h.conn == nil {
+		return nil
+	}
+	_ = h.conn.SetWriteDeadline(time.Now().Add(h.timeout))
+	return h.writer.Flush()
+}
+
+func (h *socketConnectionHandler) GetFailureCount() int64 {
+	return atomic.LoadInt64(&h.failures)
+}
+
+// Report sends pointLines over the connection. The plaintext protocol has no
+// acknowledgements, so a successful write is reported as a 200 response.
+func (h *socketConnectionHandler) Report(format string, pointLines string) (*http.Response, error) {
+	return h.ReportContext(context.Background(), format, pointLines)
+}
+
+// ReportContext is like Report, with ctx bounding connecting and writing.
+func (h *socketConnectionHandler) ReportContext(ctx context.Context, _ string, pointLines string) (*http.Response, error) {
+	if pointLines == "" {
+		return nil, formatError
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if err := h.sendLines(ctx, pointLines, h.writeThrough); err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+// writeDeadline returns the deadline of a write starting now: timeout from now,
+// or the deadline of ctx if it comes first.
+func writeDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
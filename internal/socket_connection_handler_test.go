@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPConnectionHandler_Report(t *testing.T) {
+	listener, received := listenTCP(t)
+	handler := NewTCPConnectionHandler(listener.Addr().String(), time.Second)
+	defer handler.Close()
+
+	reporter := handler.(Reporter)
+	resp, err := reporter.Report(metricFormat, "\"foo\" 1 source=\"bar\"\n\"foo\" 2 source=\"bar\"\n")
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.True(t, handler.Connected())
+	assert.Equal(t, "\"foo\" 1 source=\"bar\"", <-received)
+	assert.Equal(t, "\"foo\" 2 source=\"bar\"", <-received)
+}
+
+func TestTCPConnectionHandler_SendDataAndFlush(t *testing.T) {
+	listener, received := listenTCP(t)
+	handler := NewTCPConnectionHandler(listener.Addr().String(), time.Second)
+	defer handler.Close()
+
+	require.NoError(t, handler.SendData("line\n"))
+	require.NoError(t, handler.Flush())
+	assert.Equal(t, "line", <-received)
+}
+
+func TestTCPConnectionHandler_Reconnects(t *testing.T) {
+	listener, received := listenTCP(t)
+	handler := NewTCPConnectionHandler(listener.Addr().String(), time.Second)
+	defer handler.Close()
+
+	require.NoError(t, handler.Connect())
+	tcpHandler := handler.(*socketConnectionHandler)
+	_ = tcpHandler.conn.Close() // simulate a broken connection
+
+	_, err := handler.(Reporter).Report(metricFormat, "after reconnect\n")
+	require.NoError(t, err)
+	assert.Equal(t, "after reconnect", <-received)
+	assert.Equal(t, int64(0), handler.GetFailureCount())
+}
+
+func TestTCPConnectionHandler_ResendsUnwrittenLines(t *testing.T) {
+	listener, received := listenTCP(t)
+	handler := NewTCPConnectionHandler(listener.Addr().String(), time.Second)
+	defer handler.Close()
+
+	broken := &partialConn{limit: len("first\nsec")}
+	tcpHandler := handler.(*socketConnectionHandler)
+	tcpHandler.conn = broken
+	tcpHandler.writer = bufio.NewWriter(broken)
+
+	_, err := handler.(Reporter).Report(metricFormat, "first\nsecond\nthird\n")
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsec", broken.written.String())
+	assert.Equal(t, "second", <-received)
+	assert.Equal(t, "third", <-received)
+	select {
+	case line := <-received:
+		t.Fatalf("unexpected line %q", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTCPConnectionHandler_ConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	handler := NewTCPConnectionHandler(address, time.Second)
+	_, err = handler.(Reporter).Report(metricFormat, "line\n")
+	assert.Error(t, err)
+	assert.False(t, handler.Connected())
+	assert.Equal(t, int64(1), handler.GetFailureCount())
+}
+
+func TestUnixConnectionHandler_Report(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	received := accept(t, listener)
+
+	handler := NewUnixConnectionHandler(path, time.Second)
+	defer handler.Close()
+	_, err = handler.(Reporter).Report(metricFormat, "\"foo\" 1 source=\"bar\"\n")
+	require.NoError(t, err)
+	assert.Equal(t, "\"foo\" 1 source=\"bar\"", <-received)
+}
+
+// partialConn is a connection that fails once limit bytes were written to it.
+type partialConn struct {
+	net.Conn
+	limit   int
+	written strings.Builder
+}
+
+func (c *partialConn) Write(b []byte) (int, error) {
+	n := c.limit - c.written.Len()
+	if n >= len(b) {
+		c.written.Write(b)
+		return len(b), nil
+	}
+	c.written.Write(b[:n])
+	return n, errors.New("connection reset by peer")
+}
+
+func (c *partialConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *partialConn) Close() error { return nil }
+
+// listenTCP accepts connections on a local port and sends every line received to the returned channel.
+func listenTCP(t *testing.T) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return listener, accept(t, listener)
+}
+
+// accept sends every line received on connections accepted by listener to the returned channel.
+func accept(t *testing.T, listener net.Listener) chan string {
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return received
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/wavefronthq/wavefront-sdk-go/internal"
 )
@@ -34,9 +33,9 @@ func Line(name string, startMillis, durationMillis int64, source, traceID, spanI
 	sb := internal.GetBuffer()
 	defer internal.PutBuffer(sb)
 
-	sb.WriteString(internal.SanitizeValue(name))
+	internal.WriteSanitizedValue(sb, name)
 	sb.WriteString(" source=")
-	sb.WriteString(internal.SanitizeValue(source))
+	internal.WriteSanitizedValue(sb, source)
 	sb.WriteString(" traceId=")
 	sb.WriteString(traceID)
 	sb.WriteString(" spanId=")
@@ -54,9 +53,7 @@ func Line(name string, startMillis, durationMillis int64, source, traceID, spanI
 
 	if len(spanLogs) > 0 {
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(internal.Sanitize("_spanLogs")))
-		sb.WriteString("=")
-		sb.WriteString(strconv.Quote(internal.Sanitize("true")))
+		sb.WriteString(`"_spanLogs"="true"`)
 	}
 
 	for _, tag := range tags {
@@ -67,14 +64,14 @@ func Line(name string, startMillis, durationMillis int64, source, traceID, spanI
 			return "", fmt.Errorf("tag values cannot be empty: span=%s tag=%s", name, tag.Key)
 		}
 		sb.WriteString(" ")
-		sb.WriteString(strconv.Quote(internal.Sanitize(tag.Key)))
+		internal.WriteQuotedSanitized(sb, tag.Key)
 		sb.WriteString("=")
-		sb.WriteString(internal.SanitizeValue(tag.Value))
+		internal.WriteSanitizedValue(sb, tag.Value)
 	}
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatInt(startMillis, 10))
+	internal.WriteInt(sb, startMillis)
 	sb.WriteString(" ")
-	sb.WriteString(strconv.FormatInt(durationMillis, 10))
+	internal.WriteInt(sb, durationMillis)
 	sb.WriteString("\n")
 
 	return sb.String(), nil
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxDatagramSize keeps datagrams within the payload of a single Ethernet frame
+	// so that they are not fragmented on their way to the proxy.
+	DefaultMaxDatagramSize = 1400
+
+	// MaxUDPPayloadSize is the largest payload a UDP datagram can carry over IPv4.
+	MaxUDPPayloadSize = 65507
+)
+
+// udpConnectionHandler is a ConnectionHandler that sends lines in the Wavefront
+// plaintext format as UDP datagrams. Delivery is fire-and-forget: the proxy parses
+// every datagram on its own, so lines are packed whole into datagrams of at most
+// maxDatagramSize bytes and a line that does not fit in a datagram is dropped.
+type udpConnectionHandler struct {
+	// keep this field as first element of struct
+	// to guarantee 64-bit alignment on 32-bit machines.
+	failures int64
+
+	address         string
+	maxDatagramSize int
+	timeout         time.Duration
+
+	mtx  sync.Mutex
+	conn net.Conn
+}
+
+// NewUDPConnectionHandler creates a ConnectionHandler sending datagrams of at most
+// maxDatagramSize bytes to the UDP listener at address. timeout bounds each write.
+// The returned handler also implements Reporter, so it can be used by line handlers.
+func NewUDPConnectionHandler(address string, maxDatagramSize int, timeout time.Duration) ConnectionHandler {
+	return &udpConnectionHandler{
+		address:         address,
+		maxDatagramSize: maxDatagramSize,
+		timeout:         timeout,
+	}
+}
+
+func (h *udpConnectionHandler) Start() {
+	if err := h.Connect(); err != nil {
+		log.Printf("unable to connect to udp://%s: %v\n", h.address, err)
+	}
+}
+
+func (h *udpConnectionHandler) Connect() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.connect()
+}
+
+func (h *udpConnectionHandler) connect() error {
+	if h.conn != nil {
+		return nil
+	}
+	conn, err := net.Dial("udp", h.address)
+	if err != nil {
+		return err
+	}
+	h.conn = conn
+	return nil
+}
+
+func (h *udpConnectionHandler) Connected() bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.conn != nil
+}
+
+func (h *udpConnectionHandler) Close() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.conn != nil {
+		_ = h.conn.Close()
+		h.conn = nil
+	}
+}
+
+// SendData sends lines right away, as datagrams are not buffered.
+func (h *udpConnectionHandler) SendData(lines string) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.send(context.Background(), "", lines)
+}
+
+// Flush is a no-op, as datagrams are sent right away.
+func (h *udpConnectionHandler) Flush() error {
+	return nil
+}
+
+func (h *udpConnectionHandler) GetFailureCount() int64 {
+	return atomic.LoadInt64(&h.failures)
+}
+
+// Report sends pointLines as datagrams. As delivery is not acknowledged, the report
+// only fails when no datagram at all could be sent, so that retrying it does not
+// duplicate lines already sent.
+func (h *udpConnectionHandler) Report(format string, pointLines string) (*http.Response, error) {
+	return h.ReportContext(context.Background(), format, pointLines)
+}
+
+// ReportContext is like Report, and stops sending datagrams once ctx is done.
+func (h *udpConnectionHandler) ReportContext(ctx context.Context, format string, pointLines string) (*http.Response, error) {
+	if pointLines == "" {
+		return nil, formatError
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if err := h.send(ctx, format, pointLines); err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+// send packs lines into datagrams and sends them. It returns an error when nothing was sent.
+func (h *udpConnectionHandler) send(ctx context.Context, format string, lines string) error {
+	if err := h.connect(); err != nil {
+		atomic.AddInt64(&h.failures, 1)
+		return fmt.Errorf("error sending data to udp://%s: %v", h.address, err)
+	}
+
+	var lastErr error
+	sent := 0
+	datagram := make([]byte, 0, h.maxDatagramSize)
+	write := func() {
+		if len(datagram) == 0 {
+			return
+		}
+		defer func() { datagram = datagram[:0] }()
+		if err := ctx.Err(); err != nil {
+			lastErr = err
+			return
+		}
+		_ = h.conn.SetWriteDeadline(writeDeadline(ctx, h.timeout))
+		if _, err := h.conn.Write(datagram); err != nil {
+			atomic.AddInt64(&h.failures, 1)
+			lastErr = err
+		} else {
+			sent++
+		}
+	}
+
+	for len(lines) > 0 {
+		line := lines
+		if i := strings.IndexByte(lines, '\n'); i >= 0 {
+			line = lines[:i+1]
+		}
+		lines = lines[len(line):]
+
+		if len(line) > h.maxDatagramSize {
+			atomic.AddInt64(&h.failures, 1)
+			log.Printf("dropping line of %d bytes (format %q), larger than the max datagram size of %d bytes\n",
+				len(line), format, h.maxDatagramSize)
+			continue
+		}
+		if len(datagram)+len(line) > h.maxDatagramSize {
+			write()
+		}
+		datagram = append(datagram, line...)
+	}
+	write()
+
+	if sent == 0 && lastErr != nil {
+		return fmt.Errorf("error sending data to udp://%s: %v", h.address, lastErr)
+	}
+	return nil
+}
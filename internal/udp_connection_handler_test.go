@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUDPConnectionHandler_PacksLinesIntoDatagrams(t *testing.T) {
+	conn, datagrams := listenUDP(t)
+	handler := NewUDPConnectionHandler(conn.LocalAddr().String(), 20, time.Second)
+	defer handler.Close()
+
+	resp, err := handler.(Reporter).Report(metricFormat, "aaaaaaaa\nbbbbbbbb\ncccccccc\n")
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "aaaaaaaa\nbbbbbbbb\n", <-datagrams)
+	assert.Equal(t, "cccccccc\n", <-datagrams)
+	assert.Equal(t, int64(0), handler.GetFailureCount())
+}
+
+func TestUDPConnectionHandler_DropsOversizedLines(t *testing.T) {
+	conn, datagrams := listenUDP(t)
+	handler := NewUDPConnectionHandler(conn.LocalAddr().String(), 20, time.Second)
+	defer handler.Close()
+
+	require.NoError(t, handler.SendData("small\n"+strings.Repeat("x", 30)+"\nlast\n"))
+	assert.Equal(t, "small\nlast\n", <-datagrams)
+	assert.Equal(t, int64(1), handler.GetFailureCount())
+}
+
+// listenUDP receives datagrams on a local port and sends them to the returned channel.
+func listenUDP(t *testing.T) (net.PacketConn, chan string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	datagrams := make(chan string, 100)
+	go func() {
+		buf := make([]byte, MaxUDPPayloadSize)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			datagrams <- string(buf[:n])
+		}
+	}()
+	return conn, datagrams
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+// Counter counts events, reported as the number of events since it was created.
+type Counter struct {
+	// keep first to guarantee 64-bit alignment on 32-bit machines.
+	value int64
+	series
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Count returns the value of the counter.
+func (c *Counter) Count() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+func (c *Counter) collect(b *batch) {
+	b.points = append(b.points, c.point(float64(c.Count())))
+}
+
+// DeltaCounter counts events, reported as the number of events since it was last reported.
+// Wavefront sums the values reported by every DeltaCounter with the same name, source and tags.
+type DeltaCounter struct {
+	// keep first to guarantee 64-bit alignment on 32-bit machines.
+	value int64
+	series
+}
+
+// Inc increments the counter by 1.
+func (c *DeltaCounter) Inc() {
+	c.Add(1)
+}
+
+// Add increments the counter by n.
+func (c *DeltaCounter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Count returns the number of events since the counter was last reported.
+func (c *DeltaCounter) Count() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+func (c *DeltaCounter) collect(b *batch) {
+	delta := atomic.SwapInt64(&c.value, 0)
+	if delta <= 0 {
+		atomic.AddInt64(&c.value, delta)
+		return
+	}
+	point := c.point(float64(delta))
+	point.Name = internal.DeltaCounterName(point.Name)
+	b.points = append(b.points, point)
+}
+
+// Gauge is a value that is set.
+type Gauge struct {
+	bits uint64
+	series
+}
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(value float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(value))
+}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) collect(b *batch) {
+	b.points = append(b.points, g.point(g.Value()))
+}
+
+// FunctionalGauge is a value computed each time it is reported.
+type FunctionalGauge struct {
+	value func() float64
+	series
+}
+
+// Value returns the value of the gauge.
+func (g *FunctionalGauge) Value() float64 {
+	return g.value()
+}
+
+func (g *FunctionalGauge) collect(b *batch) {
+	b.points = append(b.points, g.point(g.Value()))
+}
+
+// Histogram is a histogram.Histogram, whose distributions are reported as they complete.
+type Histogram struct {
+	histogram.Histogram
+	series
+}
+
+func (h *Histogram) collect(b *batch) {
+	distributions := h.Distributions
+	if b.last {
+		distributions = func() []histogram.Distribution { return histogram.Drain(h.Histogram) }
+	}
+	granularities := map[histogram.Granularity]bool{h.Granularity(): true}
+	for _, distribution := range distributions() {
+		// time slices without samples would fail the validation of the whole batch.
+		if len(distribution.Centroids) == 0 {
+			continue
+		}
+		b.distributions = append(b.distributions, senders.Distribution{
+			Name:          h.name,
+			Centroids:     distribution.Centroids,
+			Granularities: granularities,
+			Timestamp:     distribution.Timestamp.Unix(),
+			Source:        h.source,
+			Tags:          h.tags,
+		})
+	}
+}
+
+// Timer is a histogram of durations, in milliseconds.
+type Timer struct {
+	histogram *Histogram
+}
+
+// Update adds d to the timer.
+func (t *Timer) Update(d time.Duration) {
+	t.histogram.Update(float64(d) / float64(time.Millisecond))
+}
+
+// UpdateSince adds the time elapsed since start to the timer.
+func (t *Timer) UpdateSince(start time.Time) {
+	t.Update(time.Since(start))
+}
+
+// Time calls f, and adds the time it took to the timer.
+func (t *Timer) Time(f func()) {
+	start := time.Now()
+	defer t.UpdateSince(start)
+	f()
+}
+
+// Histogram returns the durations added to the timer, in milliseconds.
+func (t *Timer) Histogram() histogram.Histogram {
+	return t.histogram
+}
+
+func (t *Timer) collect(b *batch) {
+	t.histogram.collect(b)
+}
+
+func (s series) point(value float64) senders.Point {
+	return senders.Point{Name: s.name, Value: value, Source: s.source, Tags: s.tags}
+}
@@ -0,0 +1,280 @@
+// Package metrics provides a registry of counters, gauges, timers and histograms,
+// reported to Wavefront at a regular interval by a senders.Sender.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/internal/key"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+const defaultReportInterval = time.Minute
+
+// Registry holds metrics identified by their name and tags, and reports them with a Sender.
+// Counters and gauges are reported as points, delta counters as delta counters, and timers
+// and histograms as distributions.
+type Registry struct {
+	sender   senders.Sender
+	interval time.Duration
+	source   string
+	prefix   string
+	tags     map[string]string
+
+	mtx     sync.Mutex
+	metrics map[string]metric
+
+	ticker *time.Ticker
+	stop   chan struct{}
+}
+
+// Option configures a Registry.
+type Option func(*Registry)
+
+// ReportInterval sets how often the metrics are reported, every minute by default,
+// which is also used when interval is not positive.
+func ReportInterval(interval time.Duration) Option {
+	return func(r *Registry) {
+		r.interval = interval
+	}
+}
+
+// Source sets the source of the metrics, the default source of the Sender by default.
+func Source(source string) Option {
+	return func(r *Registry) {
+		r.source = source
+	}
+}
+
+// Prefix sets the prefix of the names of the metrics, separated from the names by a dot.
+func Prefix(prefix string) Option {
+	return func(r *Registry) {
+		r.prefix = prefix
+	}
+}
+
+// Tags sets tags added to the tags of every metric. The tags with an empty key or value,
+// which Wavefront would reject along with every metric, are left out.
+func Tags(tags map[string]string) Option {
+	return func(r *Registry) {
+		r.tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			if k == "" || v == "" {
+				log.Printf("metrics registry: empty key or value for tag '%s', the tag is left out\n", k)
+				continue
+			}
+			r.tags[k] = v
+		}
+	}
+}
+
+// metric is implemented by the metrics of a Registry.
+type metric interface {
+	// collect adds the data to report for the metric to b.
+	collect(b *batch)
+}
+
+// batch is the data a Registry reports at once.
+type batch struct {
+	points        []senders.Point
+	distributions []senders.Distribution
+	// last is set when the metrics are reported a last time, so that the distributions
+	// of the current time slices of histograms are reported as well.
+	last bool
+}
+
+// series is the identity of a metric.
+type series struct {
+	name   string
+	source string
+	tags   map[string]string
+}
+
+// NewRegistry creates a Registry reporting its metrics with sender once started.
+func NewRegistry(sender senders.Sender, setters ...Option) *Registry {
+	r := &Registry{
+		sender:   sender,
+		interval: defaultReportInterval,
+		metrics:  make(map[string]metric),
+	}
+	for _, setter := range setters {
+		setter(r)
+	}
+	if r.interval <= 0 {
+		r.interval = defaultReportInterval
+	}
+	return r
+}
+
+// Start reports the metrics at every interval, until Stop is called. Starting a started
+// Registry does nothing.
+func (r *Registry) Start() {
+	if r.ticker != nil {
+		return
+	}
+	r.ticker = time.NewTicker(r.interval)
+	r.stop = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-r.ticker.C:
+				if err := r.Report(context.Background()); err != nil {
+					log.Printf("metrics registry: error reporting metrics: %v\n", err)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops reporting the metrics, and reports them a last time, including the distributions
+// of every time slice of the histograms, as histogram.Drain does.
+func (r *Registry) Stop() error {
+	if r.ticker != nil {
+		r.ticker.Stop()
+		r.stop <- struct{}{} // block until goroutine exits
+		r.ticker = nil
+	}
+	return r.report(context.Background(), true)
+}
+
+// Report reports the metrics now. It returns the error of the points first, if any,
+// and else the error of the distributions.
+func (r *Registry) Report(ctx context.Context) error {
+	return r.report(ctx, false)
+}
+
+func (r *Registry) report(ctx context.Context, last bool) error {
+	b := batch{last: last}
+	r.mtx.Lock()
+	for _, m := range r.metrics {
+		m.collect(&b)
+	}
+	r.mtx.Unlock()
+
+	var pointsErr, distributionsErr error
+	if len(b.points) > 0 {
+		pointsErr = r.sender.SendPoints(ctx, b.points)
+	}
+	if len(b.distributions) > 0 {
+		distributionsErr = r.sender.SendDistributions(ctx, b.distributions)
+	}
+	if pointsErr == nil {
+		return distributionsErr
+	}
+	if distributionsErr != nil {
+		log.Printf("metrics registry: error reporting distributions: %v\n", distributionsErr)
+	}
+	return pointsErr
+}
+
+// Counter returns the counter called name with tags, creating it if needed.
+// Counters are reported as the number of increments since they were created.
+func (r *Registry) Counter(name string, tags map[string]string) *Counter {
+	return r.getOrAdd(name, tags, func(s series) metric {
+		return &Counter{series: s}
+	}).(*Counter)
+}
+
+// DeltaCounter returns the delta counter called name with tags, creating it if needed.
+// Delta counters are reported as the number of increments since they were last reported,
+// and aggregated by Wavefront.
+func (r *Registry) DeltaCounter(name string, tags map[string]string) *DeltaCounter {
+	return r.getOrAdd(name, tags, func(s series) metric {
+		return &DeltaCounter{series: s}
+	}).(*DeltaCounter)
+}
+
+// Gauge returns the gauge called name with tags, creating it if needed.
+func (r *Registry) Gauge(name string, tags map[string]string) *Gauge {
+	return r.getOrAdd(name, tags, func(s series) metric {
+		return &Gauge{series: s}
+	}).(*Gauge)
+}
+
+// FunctionalGauge returns the gauge called name with tags, whose value is f, creating it if needed.
+// f is called each time the metrics are reported.
+func (r *Registry) FunctionalGauge(name string, tags map[string]string, f func() float64) *FunctionalGauge {
+	return r.getOrAdd(name, tags, func(s series) metric {
+		return &FunctionalGauge{series: s, value: f}
+	}).(*FunctionalGauge)
+}
+
+// Histogram returns the histogram called name with tags, creating it with setters if needed.
+// The distributions of the completed time slices of histograms are reported.
+func (r *Registry) Histogram(name string, tags map[string]string, setters ...histogram.Option) *Histogram {
+	return r.getOrAdd(name, tags, func(s series) metric {
+		return &Histogram{Histogram: histogram.New(setters...), series: s}
+	}).(*Histogram)
+}
+
+// Timer returns the timer called name with tags, creating it with setters if needed.
+// Timers are histograms of durations in milliseconds.
+func (r *Registry) Timer(name string, tags map[string]string, setters ...histogram.Option) *Timer {
+	return r.getOrAdd(name, tags, func(s series) metric {
+		return &Timer{histogram: &Histogram{Histogram: histogram.New(setters...), series: s}}
+	}).(*Timer)
+}
+
+// Remove removes the metric called name with tags, which is no longer reported.
+func (r *Registry) Remove(name string, tags map[string]string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.metrics, key.Of(name, tags))
+}
+
+// getOrAdd returns the metric called name with tags, adding the one newMetric creates if needed.
+// If name, or a tag value, is empty, Wavefront would reject the metric, so the metric newMetric
+// creates is returned without being added, and is never reported. It panics if the metric has
+// another type, which the caller asserts.
+func (r *Registry) getOrAdd(name string, tags map[string]string, newMetric func(series) metric) metric {
+	if err := validate(name, tags); err != nil {
+		log.Printf("metrics registry: %v, the metric is not reported\n", err)
+		return newMetric(r.series(name, tags))
+	}
+
+	k := key.Of(name, tags)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if m, ok := r.metrics[k]; ok {
+		return m
+	}
+	m := newMetric(r.series(name, tags))
+	r.metrics[k] = m
+	return m
+}
+
+// validate returns an error if name, or a tag value, is empty.
+func validate(name string, tags map[string]string) error {
+	if name == "" {
+		return errors.New("empty metric name")
+	}
+	for k, v := range tags {
+		if v == "" {
+			return fmt.Errorf("empty value for tag %s of metric %s", k, name)
+		}
+	}
+	return nil
+}
+
+// series returns the identity of the metric called name with tags, as it is reported.
+func (r *Registry) series(name string, tags map[string]string) series {
+	if r.prefix != "" {
+		name = r.prefix + "." + name
+	}
+	merged := make(map[string]string, len(r.tags)+len(tags))
+	for k, v := range r.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return series{name: name, source: r.source, tags: merged}
+}
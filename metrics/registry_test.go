@@ -0,0 +1,201 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/metrics"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+// recordingSender records the batches of points and distributions it is sent.
+type recordingSender struct {
+	senders.Sender
+	mtx           sync.Mutex
+	points        []senders.Point
+	distributions []senders.Distribution
+	err           error
+}
+
+func (s *recordingSender) SendPoints(_ context.Context, points []senders.Point) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.points = append(s.points, points...)
+	return s.err
+}
+
+func (s *recordingSender) SendDistributions(_ context.Context, distributions []senders.Distribution) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.distributions = append(s.distributions, distributions...)
+	return s.err
+}
+
+func (s *recordingSender) reported() []senders.Point {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	points := s.points
+	s.points = nil
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Name < points[j].Name
+	})
+	return points
+}
+
+func TestRegistry(t *testing.T) {
+	sender := &recordingSender{}
+	registry := metrics.NewRegistry(sender, metrics.Prefix("app"), metrics.Source("host"),
+		metrics.Tags(map[string]string{"env": "prod"}))
+
+	requests := registry.Counter("requests", map[string]string{"path": "/users"})
+	requests.Inc()
+	assert.Same(t, requests, registry.Counter("requests", map[string]string{"path": "/users"}))
+	registry.Counter("requests", map[string]string{"path": "/users"}).Add(2)
+	registry.Counter("requests", map[string]string{"path": "/groups"}).Inc()
+	errs := registry.DeltaCounter("errors", nil)
+	errs.Add(5)
+	registry.Gauge("temperature", nil).Set(21.5)
+	registry.FunctionalGauge("goroutines", nil, func() float64 { return 7 })
+
+	require.NoError(t, registry.Report(context.Background()))
+	prod := func(tags map[string]string) map[string]string {
+		tags["env"] = "prod"
+		return tags
+	}
+	assert.ElementsMatch(t, []senders.Point{
+		{Name: "app.goroutines", Value: 7, Source: "host", Tags: prod(map[string]string{})},
+		{Name: "app.requests", Value: 3, Source: "host", Tags: prod(map[string]string{"path": "/users"})},
+		{Name: "app.requests", Value: 1, Source: "host", Tags: prod(map[string]string{"path": "/groups"})},
+		{Name: "app.temperature", Value: 21.5, Source: "host", Tags: prod(map[string]string{})},
+		{Name: "∆app.errors", Value: 5, Source: "host", Tags: prod(map[string]string{})},
+	}, sender.reported())
+	assert.Zero(t, errs.Count())
+
+	require.NoError(t, registry.Report(context.Background()))
+	points := sender.reported()
+	assert.Len(t, points, 4, "delta counters are not reported without increments")
+	assert.Contains(t, points, senders.Point{Name: "app.requests", Value: 3, Source: "host",
+		Tags: prod(map[string]string{"path": "/users"})}, "counters are cumulative")
+
+	registry.Remove("requests", map[string]string{"path": "/groups"})
+	require.NoError(t, registry.Report(context.Background()))
+	assert.Len(t, sender.reported(), 3)
+}
+
+func TestRegistry_Histograms(t *testing.T) {
+	sender := &recordingSender{}
+	registry := metrics.NewRegistry(sender)
+	now := time.Unix(1700000000, 0)
+	clock := histogram.TimeSupplier(func() time.Time { return now })
+
+	latency := registry.Histogram("latency", map[string]string{"path": "/users"}, clock)
+	latency.Update(10)
+	latency.Update(20)
+	timer := registry.Timer("query", nil, clock, histogram.GranularityOption(histogram.HOUR))
+	timer.Update(1500 * time.Microsecond)
+	timer.Time(func() {})
+
+	require.NoError(t, registry.Report(context.Background()))
+	assert.Empty(t, sender.distributions, "distributions are reported once complete")
+
+	now = now.Add(time.Hour)
+	assert.InDelta(t, 1.5, timer.Histogram().Max(), 0.001)
+	require.NoError(t, registry.Report(context.Background()))
+	require.Len(t, sender.distributions, 2)
+	sort.Slice(sender.distributions, func(i, j int) bool {
+		return sender.distributions[i].Name < sender.distributions[j].Name
+	})
+	assert.Equal(t, "latency", sender.distributions[0].Name)
+	assert.Equal(t, map[histogram.Granularity]bool{histogram.MINUTE: true}, sender.distributions[0].Granularities)
+	assert.Equal(t, int64(1699999980), sender.distributions[0].Timestamp)
+	assert.Equal(t, map[string]string{"path": "/users"}, sender.distributions[0].Tags)
+	assert.Len(t, sender.distributions[0].Centroids, 2)
+	assert.Equal(t, "query", sender.distributions[1].Name)
+	assert.Equal(t, map[histogram.Granularity]bool{histogram.HOUR: true}, sender.distributions[1].Granularities)
+
+	require.NoError(t, registry.Report(context.Background()))
+	assert.Len(t, sender.distributions, 2, "distributions are reported once")
+}
+
+func TestRegistry_StartStop(t *testing.T) {
+	sender := &recordingSender{err: errors.New("buffer full")}
+	registry := metrics.NewRegistry(sender, metrics.ReportInterval(10*time.Millisecond))
+	registry.Counter("requests", nil).Inc()
+	registry.Start()
+	assert.Eventually(t, func() bool {
+		sender.mtx.Lock()
+		defer sender.mtx.Unlock()
+		return len(sender.points) >= 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Error(t, registry.Stop())
+}
+
+func TestRegistry_StartTwice(t *testing.T) {
+	sender := &recordingSender{}
+	registry := metrics.NewRegistry(sender, metrics.ReportInterval(time.Millisecond))
+	registry.Counter("requests", nil).Inc()
+	registry.Start()
+	registry.Start()
+	assert.Eventually(t, func() bool {
+		sender.mtx.Lock()
+		defer sender.mtx.Unlock()
+		return len(sender.points) > 0
+	}, time.Second, time.Millisecond)
+	require.NoError(t, registry.Stop())
+
+	sender.reported()
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, sender.reported(), "nothing is reported after Stop")
+}
+
+func TestRegistry_InvalidOptions(t *testing.T) {
+	sender := &recordingSender{}
+	registry := metrics.NewRegistry(sender, metrics.ReportInterval(0),
+		metrics.Tags(map[string]string{"env": "prod", "region": "", "": "none"}))
+	registry.Start()
+	defer registry.Stop()
+
+	registry.Counter("requests", nil).Inc()
+	require.NoError(t, registry.Report(context.Background()))
+	points := sender.reported()
+	require.Len(t, points, 1)
+	assert.Equal(t, map[string]string{"env": "prod"}, points[0].Tags)
+}
+
+func TestRegistry_InvalidMetrics(t *testing.T) {
+	sender := &recordingSender{}
+	registry := metrics.NewRegistry(sender)
+	registry.Counter("", nil).Inc()
+	registry.Gauge("temperature", map[string]string{"room": ""}).Set(21)
+	require.NoError(t, registry.Report(context.Background()))
+	assert.Empty(t, sender.reported(), "invalid metrics are not reported")
+
+	registry.Counter("requests", nil)
+	assert.Panics(t, func() { registry.Gauge("requests", nil) })
+}
+
+func TestRegistry_StopReportsCurrentTimeSlices(t *testing.T) {
+	sender := &recordingSender{}
+	registry := metrics.NewRegistry(sender)
+	now := time.Unix(1700000000, 0)
+	clock := histogram.TimeSupplier(func() time.Time { return now })
+
+	registry.Histogram("latency", nil, clock).Update(10)
+	registry.Timer("query", nil, clock).Update(time.Millisecond)
+	require.NoError(t, registry.Report(context.Background()))
+	assert.Empty(t, sender.distributions)
+
+	require.NoError(t, registry.Stop())
+	require.Len(t, sender.distributions, 2)
+	for _, distribution := range sender.distributions {
+		assert.Equal(t, int64(1699999980), distribution.Timestamp)
+		assert.Len(t, distribution.Centroids, 1)
+	}
+}
@@ -0,0 +1,472 @@
+// Package prometheus forwards metrics in the Prometheus text exposition format to Wavefront,
+// for libraries instrumented with Prometheus collectors.
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+const textContentType = "text/plain; version=0.0.4"
+
+// Source returns metrics in the Prometheus text exposition format.
+type Source interface {
+	Scrape(ctx context.Context) (io.ReadCloser, error)
+}
+
+// SourceFunc is a Source calling the function.
+type SourceFunc func(ctx context.Context) (io.ReadCloser, error)
+
+// Scrape calls f.
+func (f SourceFunc) Scrape(ctx context.Context) (io.ReadCloser, error) {
+	return f(ctx)
+}
+
+// URLSource is a Source scraping the metrics served at url, such as a local /metrics endpoint.
+func URLSource(url string) Source {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return SourceFunc(func(ctx context.Context) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", textContentType)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error scraping %s: %s", url, resp.Status)
+		}
+		return resp.Body, nil
+	})
+}
+
+// HandlerSource is a Source scraping the metrics served by handler in the process, without listening.
+// A prometheus.Gatherer is scraped with the handler promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}).
+func HandlerSource(handler http.Handler) Source {
+	return SourceFunc(func(ctx context.Context) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/metrics", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", textContentType)
+		w := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		handler.ServeHTTP(w, req)
+		if w.status != http.StatusOK {
+			return nil, fmt.Errorf("error scraping the handler: %d %s", w.status, strings.TrimSpace(w.body.String()))
+		}
+		return io.NopCloser(&w.body), nil
+	})
+}
+
+// responseRecorder records the response of a handler.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+}
+
+// Bridge scrapes a Source at a regular interval, and sends its metrics with a Sender: counters as
+// delta counters, gauges and untyped metrics as metrics, and histograms and summaries as distributions.
+// Counters, histograms and summaries are cumulative in Prometheus, so the increments since the
+// previous scrape are sent, and nothing is sent for them on the first scrape.
+type Bridge struct {
+	sender   senders.Sender
+	source   Source
+	interval time.Duration
+	prefix   string
+	host     string
+	tags     map[string]string
+
+	// serializes reports, which update the values of the previous scrape.
+	mtx      sync.Mutex
+	previous map[string]float64
+
+	ticker *time.Ticker
+	stop   chan struct{}
+}
+
+// Option configures a Bridge.
+type Option func(*Bridge)
+
+// ReportInterval sets how often the source is scraped, every minute by default,
+// which is also used when interval is not positive.
+func ReportInterval(interval time.Duration) Option {
+	return func(b *Bridge) {
+		b.interval = interval
+	}
+}
+
+// Prefix sets the prefix of the names of the metrics, separated from the names by a dot.
+func Prefix(prefix string) Option {
+	return func(b *Bridge) {
+		b.prefix = prefix
+	}
+}
+
+// ReportSource sets the source of the metrics, the default source of the Sender by default.
+func ReportSource(source string) Option {
+	return func(b *Bridge) {
+		b.host = source
+	}
+}
+
+// Tags sets tags added to the labels of every metric. Labels take precedence over tags with the same key.
+func Tags(tags map[string]string) Option {
+	return func(b *Bridge) {
+		b.tags = tags
+	}
+}
+
+// NewBridge creates a Bridge sending the metrics of source with sender once started.
+func NewBridge(sender senders.Sender, source Source, setters ...Option) *Bridge {
+	b := &Bridge{
+		sender:   sender,
+		source:   source,
+		interval: time.Minute,
+		previous: make(map[string]float64),
+	}
+	for _, setter := range setters {
+		setter(b)
+	}
+	if b.interval <= 0 {
+		b.interval = time.Minute
+	}
+	return b
+}
+
+// Start scrapes the source at every interval, until Stop is called.
+func (b *Bridge) Start() {
+	if b.ticker != nil {
+		return
+	}
+	b.ticker = time.NewTicker(b.interval)
+	b.stop = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-b.ticker.C:
+				if err := b.Report(context.Background()); err != nil {
+					log.Printf("prometheus bridge: error reporting metrics: %v\n", err)
+				}
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops scraping the source.
+func (b *Bridge) Stop() {
+	if b.ticker != nil {
+		b.ticker.Stop()
+		b.stop <- struct{}{} // block until goroutine exits
+		b.ticker = nil
+	}
+}
+
+// Report scrapes the source and sends its metrics now. It returns the number of metrics
+// that could not be sent along with the first error.
+func (b *Bridge) Report(ctx context.Context) error {
+	body, err := b.source.Scrape(ctx)
+	if err != nil {
+		return err
+	}
+	samples, err := parse(body)
+	body.Close()
+	if err != nil {
+		return fmt.Errorf("error parsing metrics: %w", err)
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	r := &report{
+		Bridge:        b,
+		current:       make(map[string]float64),
+		distributions: make(map[string]*distribution),
+		ts:            time.Now().Unix(),
+	}
+	for _, s := range samples {
+		r.add(s)
+	}
+	r.sendDistributions()
+	// series that are gone start over if they come back.
+	b.previous = r.current
+
+	if r.firstErr != nil {
+		return fmt.Errorf("%d metrics failed to be sent, first error: %w", r.failed, r.firstErr)
+	}
+	return nil
+}
+
+// report is the conversion of the samples of a scrape.
+type report struct {
+	*Bridge
+	// the cumulative values of the scrape, by series.
+	current map[string]float64
+	// the histograms and summaries, by series.
+	distributions map[string]*distribution
+	order         []string
+	ts            int64
+
+	failed   int
+	firstErr error
+}
+
+// distribution is a histogram or a summary, whose samples are sent as a distribution.
+type distribution struct {
+	name string
+	key  string
+	tags map[string]string
+	// the upper bounds of the buckets of a histogram and their cumulative counts,
+	// or the quantiles of a summary and their values.
+	bounds  []float64
+	values  []float64
+	count   float64
+	summary bool
+}
+
+func (r *report) add(s sample) {
+	switch s.familyType {
+	case typeCounter:
+		key := seriesKey(s.family, s.labels)
+		if delta, ok := r.delta(key, s.value); ok && delta > 0 {
+			r.check(r.sender.SendDeltaCounter(r.name(s.family), delta, r.host, r.tagsOf(s.labels, "")))
+		}
+	case typeHistogram, typeSummary:
+		r.addToDistribution(s)
+	default:
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			return
+		}
+		// samples have their timestamp in milliseconds, and metrics in seconds.
+		r.check(r.sender.SendMetric(r.name(s.family), s.value, s.timestamp/1000, r.host, r.tagsOf(s.labels, "")))
+	}
+}
+
+func (r *report) addToDistribution(s sample) {
+	boundLabel := "le"
+	if s.familyType == typeSummary {
+		boundLabel = "quantile"
+	}
+	key := seriesKey(s.family, withoutLabel(s.labels, boundLabel))
+	d, ok := r.distributions[key]
+	if !ok {
+		d = &distribution{
+			name:    r.name(s.family),
+			key:     key,
+			tags:    r.tagsOf(s.labels, boundLabel),
+			summary: s.familyType == typeSummary,
+		}
+		r.distributions[key] = d
+		r.order = append(r.order, key)
+	}
+
+	switch s.suffix {
+	case "":
+		if d.summary {
+			if q, err := strconv.ParseFloat(s.labels["quantile"], 64); err == nil {
+				d.bounds = append(d.bounds, q)
+				d.values = append(d.values, s.value)
+			}
+		}
+	case "_bucket":
+		if le, err := strconv.ParseFloat(s.labels["le"], 64); err == nil {
+			d.bounds = append(d.bounds, le)
+			d.values = append(d.values, s.value)
+		}
+	case "_count":
+		d.count = s.value
+	}
+}
+
+func (r *report) sendDistributions() {
+	granularities := map[histogram.Granularity]bool{histogram.MINUTE: true}
+	for _, key := range r.order {
+		d := r.distributions[key]
+		var centroids []histogram.Centroid
+		if d.summary {
+			centroids = r.summaryCentroids(d)
+		} else {
+			centroids = r.histogramCentroids(d)
+		}
+		if len(centroids) > 0 {
+			r.check(r.sender.SendDistribution(d.name, centroids, granularities, r.ts, r.host, d.tags))
+		}
+	}
+}
+
+// histogramCentroids returns a centroid for each bucket of d with samples since the previous scrape,
+// at the middle of the bucket. The first bucket starts at 0, or at its bound if it is not positive,
+// and the +Inf bucket has its centroid at the largest finite bound.
+func (r *report) histogramCentroids(d *distribution) []histogram.Centroid {
+	indices := make([]int, len(d.bounds))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return d.bounds[indices[i]] < d.bounds[indices[j]]
+	})
+
+	var centroids []histogram.Centroid
+	var lower, previousCount float64
+	for n, i := range indices {
+		bound := d.bounds[i]
+		delta, ok := r.delta(d.key+"\x00le="+strconv.FormatFloat(bound, 'g', -1, 64), d.values[i])
+		count := delta - previousCount
+		previousCount = delta
+		if n == 0 {
+			lower = math.Min(bound, 0)
+		}
+		value := (lower + bound) / 2
+		if math.IsInf(bound, 1) {
+			value = lower
+		}
+		lower = bound
+		if ok && count >= 1 {
+			centroids = append(centroids, histogram.Centroid{Value: value, Count: int(count)})
+		}
+	}
+	return centroids
+}
+
+// summaryCentroids returns a centroid for each quantile of d, holding the share of the samples since the
+// previous scrape between the previous quantile and this one. Summaries only keep a few quantiles of a
+// sliding window of samples, so the distribution is an approximation.
+func (r *report) summaryCentroids(d *distribution) []histogram.Centroid {
+	delta, ok := r.delta(d.key+"\x00count", d.count)
+	if !ok || delta < 1 {
+		return nil
+	}
+	indices := make([]int, 0, len(d.bounds))
+	for i := range d.bounds {
+		if !math.IsNaN(d.values[i]) {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		return nil
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return d.bounds[indices[i]] < d.bounds[indices[j]]
+	})
+
+	total := int(delta)
+	var centroids []histogram.Centroid
+	var counted int
+	for n, i := range indices {
+		upTo := int(math.Round(float64(total) * d.bounds[i]))
+		if n == len(indices)-1 {
+			// the samples above the highest quantile are closest to it.
+			upTo = total
+		}
+		if count := upTo - counted; count > 0 {
+			centroids = append(centroids, histogram.Centroid{Value: d.values[i], Count: count})
+			counted = upTo
+		}
+	}
+	return centroids
+}
+
+// delta records the cumulative value of the series identified by key, and returns its increment
+// since the previous scrape, or the value if it was reset. It returns false on the first scrape.
+func (r *report) delta(key string, value float64) (float64, bool) {
+	r.current[key] = value
+	previous, ok := r.previous[key]
+	if !ok {
+		return 0, false
+	}
+	if value < previous {
+		return value, true
+	}
+	return value - previous, true
+}
+
+// check counts err if it is not nil.
+func (r *report) check(err error) {
+	if err != nil {
+		r.failed++
+		if r.firstErr == nil {
+			r.firstErr = err
+		}
+	}
+}
+
+// name returns the name of the Prometheus family as it is reported.
+func (b *Bridge) name(family string) string {
+	if b.prefix != "" {
+		family = b.prefix + "." + family
+	}
+	return internal.Sanitize(family)
+}
+
+// tagsOf returns the tags of a series with labels, without the label skipped.
+// Labels with an empty value are the same as missing labels in Prometheus, and Wavefront rejects them.
+func (b *Bridge) tagsOf(labels map[string]string, skipped string) map[string]string {
+	tags := make(map[string]string, len(b.tags)+len(labels))
+	for k, v := range b.tags {
+		tags[k] = v
+	}
+	for k, v := range labels {
+		if k != skipped && v != "" {
+			tags[internal.Sanitize(k)] = v
+		}
+	}
+	return tags
+}
+
+func withoutLabel(labels map[string]string, skipped string) map[string]string {
+	without := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != skipped {
+			without[k] = v
+		}
+	}
+	return without
+}
+
+// seriesKey identifies the series of the family with labels.
+func seriesKey(family string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(family)
+	for _, k := range keys {
+		sb.WriteString("\x00")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(labels[k])
+	}
+	return sb.String()
+}
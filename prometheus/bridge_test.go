@@ -0,0 +1,184 @@
+package prometheus_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/prometheus"
+	"github.com/wavefronthq/wavefront-sdk-go/senders"
+)
+
+type sent struct {
+	name      string
+	value     float64
+	ts        int64
+	centroids []histogram.Centroid
+	tags      map[string]string
+}
+
+// recordingSender records the metrics, delta counters and distributions it is sent.
+type recordingSender struct {
+	senders.Sender
+	metrics       []sent
+	deltas        []sent
+	distributions []sent
+}
+
+func (s *recordingSender) SendMetric(name string, value float64, ts int64, _ string, tags map[string]string) error {
+	s.metrics = append(s.metrics, sent{name: name, value: value, ts: ts, tags: tags})
+	return nil
+}
+
+func (s *recordingSender) SendDeltaCounter(name string, value float64, _ string, tags map[string]string) error {
+	s.deltas = append(s.deltas, sent{name: name, value: value, tags: tags})
+	return nil
+}
+
+func (s *recordingSender) SendDistribution(name string, centroids []histogram.Centroid, _ map[histogram.Granularity]bool, _ int64, _ string, tags map[string]string) error {
+	s.distributions = append(s.distributions, sent{name: name, centroids: centroids, tags: tags})
+	return nil
+}
+
+func (s *recordingSender) reset() {
+	s.metrics, s.deltas, s.distributions = nil, nil, nil
+}
+
+// scrapes returns a Source returning the texts in turn.
+func scrapes(texts ...string) prometheus.Source {
+	return prometheus.SourceFunc(func(context.Context) (io.ReadCloser, error) {
+		text := texts[0]
+		texts = texts[1:]
+		return io.NopCloser(strings.NewReader(text)), nil
+	})
+}
+
+const exposition = `
+# TYPE http_requests_total counter
+http_requests_total{code="200",empty=""} %d
+# TYPE memory:used gauge
+memory:used %d
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} %d
+latency_seconds_bucket{le="1"} %d
+latency_seconds_bucket{le="+Inf"} %d
+latency_seconds_sum 10
+latency_seconds_count %[5]d
+# TYPE rpc_seconds summary
+rpc_seconds{quantile="0.5"} 0.2
+rpc_seconds{quantile="0.9"} 0.8
+rpc_seconds_sum 5
+rpc_seconds_count %d
+`
+
+func TestBridge(t *testing.T) {
+	sender := &recordingSender{}
+	bridge := prometheus.NewBridge(sender, scrapes(
+		fmt.Sprintf(exposition, 10, 100, 1, 2, 3, 10),
+		fmt.Sprintf(exposition, 15, 200, 3, 6, 8, 20),
+		fmt.Sprintf(exposition, 4, 300, 3, 6, 8, 20),
+	), prometheus.Prefix("app"), prometheus.Tags(map[string]string{"env": "prod", "code": "none"}))
+
+	require.NoError(t, bridge.Report(context.Background()))
+	assert.Equal(t, []sent{{name: "app.memory-used", value: 100, tags: map[string]string{"env": "prod", "code": "none"}}},
+		sender.metrics)
+	assert.Empty(t, sender.deltas, "cumulative metrics are sent from the second scrape")
+	assert.Empty(t, sender.distributions)
+
+	sender.reset()
+	require.NoError(t, bridge.Report(context.Background()))
+	assert.Equal(t, []sent{{name: "app.http_requests_total", value: 5, tags: map[string]string{"env": "prod", "code": "200"}}},
+		sender.deltas)
+	require.Len(t, sender.distributions, 2)
+	assert.Equal(t, sent{
+		name:      "app.latency_seconds",
+		centroids: []histogram.Centroid{{Value: 0.05, Count: 2}, {Value: 0.55, Count: 2}, {Value: 1, Count: 1}},
+		tags:      map[string]string{"env": "prod", "code": "none"},
+	}, sender.distributions[0])
+	assert.Equal(t, []histogram.Centroid{{Value: 0.2, Count: 5}, {Value: 0.8, Count: 5}}, sender.distributions[1].centroids)
+
+	sender.reset()
+	require.NoError(t, bridge.Report(context.Background()))
+	assert.Equal(t, 4.0, sender.deltas[0].value, "counters restart from 0 when reset")
+	assert.Empty(t, sender.distributions, "distributions without samples are not sent")
+}
+
+func TestBridge_Gauges(t *testing.T) {
+	sender := &recordingSender{}
+	bridge := prometheus.NewBridge(sender, scrapes(`
+# TYPE temperature gauge
+temperature{room="kitchen"} 21.5 1700000000123
+temperature{room="attic"} NaN
+temperature{room="cellar"} +Inf
+temperature{room="roof"} -Inf
+`))
+	require.NoError(t, bridge.Report(context.Background()))
+	assert.Equal(t, []sent{{name: "temperature", value: 21.5, ts: 1700000000, tags: map[string]string{"room": "kitchen"}}},
+		sender.metrics, "timestamps are sent in seconds, and values that are not numbers are not sent")
+}
+
+func TestBridge_StartTwice(t *testing.T) {
+	var scraped int64
+	source := prometheus.SourceFunc(func(context.Context) (io.ReadCloser, error) {
+		atomic.AddInt64(&scraped, 1)
+		return io.NopCloser(strings.NewReader("temperature 21.5\n")), nil
+	})
+	bridge := prometheus.NewBridge(discardingSender{}, source, prometheus.ReportInterval(time.Millisecond))
+	bridge.Start()
+	bridge.Start()
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&scraped) > 0 }, time.Second, time.Millisecond)
+	bridge.Stop()
+
+	stopped := atomic.LoadInt64(&scraped)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt64(&scraped), "no scrape after Stop")
+}
+
+func TestBridge_InvalidInterval(t *testing.T) {
+	bridge := prometheus.NewBridge(discardingSender{}, scrapes(), prometheus.ReportInterval(0))
+	bridge.Start()
+	bridge.Stop()
+}
+
+// discardingSender discards the metrics it is sent.
+type discardingSender struct {
+	senders.Sender
+}
+
+func (discardingSender) SendMetric(string, float64, int64, string, map[string]string) error {
+	return nil
+}
+
+func TestBridge_Sources(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "# TYPE temperature gauge")
+		fmt.Fprintln(w, "temperature 21.5")
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	for name, source := range map[string]prometheus.Source{
+		"handler": prometheus.HandlerSource(handler),
+		"url":     prometheus.URLSource(server.URL),
+	} {
+		t.Run(name, func(t *testing.T) {
+			sender := &recordingSender{}
+			require.NoError(t, prometheus.NewBridge(sender, source).Report(context.Background()))
+			assert.Equal(t, []sent{{name: "temperature", value: 21.5, tags: map[string]string{}}}, sender.metrics)
+		})
+	}
+
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	assert.Error(t, prometheus.NewBridge(&recordingSender{}, prometheus.HandlerSource(failing)).Report(context.Background()))
+	assert.Error(t, prometheus.NewBridge(&recordingSender{}, scrapes("requests{")).Report(context.Background()))
+}
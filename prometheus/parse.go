@@ -0,0 +1,151 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Metric types of the text exposition format.
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+	typeSummary   = "summary"
+	typeUntyped   = "untyped"
+)
+
+// sample is a sample of the text exposition format, along with the metric family it belongs to.
+type sample struct {
+	family     string
+	familyType string
+	// suffix is the suffix of the name of the sample after the name of its family,
+	// like _bucket, _sum and _count for histograms.
+	suffix    string
+	labels    map[string]string
+	value     float64
+	timestamp int64 // in milliseconds, 0 if the sample has none.
+}
+
+// parse parses the samples of metrics in the Prometheus text exposition format, version 0.0.4.
+func parse(r io.Reader) ([]sample, error) {
+	types := make(map[string]string)
+	var samples []sample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+		s, err := parseSample(line, types)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+func parseSample(line string, types map[string]string) (sample, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample{}, fmt.Errorf("invalid sample '%s'", line)
+	}
+	name, rest := line[:end], line[end:]
+
+	s := sample{labels: make(map[string]string)}
+	if rest[0] == '{' {
+		var err error
+		if rest, err = parseLabels(rest[1:], s.labels); err != nil {
+			return sample{}, fmt.Errorf("invalid labels of %s: %w", name, err)
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return sample{}, fmt.Errorf("invalid value of %s: '%s'", name, strings.TrimSpace(rest))
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample{}, fmt.Errorf("invalid value of %s: %w", name, err)
+	}
+	s.value = value
+	if len(fields) == 2 {
+		if s.timestamp, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return sample{}, fmt.Errorf("invalid timestamp of %s: %w", name, err)
+		}
+	}
+
+	s.family, s.familyType, s.suffix = family(name, types)
+	return s, nil
+}
+
+// parseLabels parses the labels of a sample into labels, up to the closing brace,
+// and returns the rest of the line.
+func parseLabels(line string, labels map[string]string) (string, error) {
+	for {
+		line = strings.TrimLeft(line, " \t,")
+		if line == "" {
+			return "", fmt.Errorf("missing closing brace")
+		}
+		if line[0] == '}' {
+			return line[1:], nil
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 || eq+1 >= len(line) || line[eq+1] != '"' {
+			return "", fmt.Errorf("invalid label '%s'", line)
+		}
+		key := strings.TrimSpace(line[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+				switch line[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(line[i])
+				}
+				continue
+			}
+			value.WriteByte(line[i])
+		}
+		if i == len(line) {
+			return "", fmt.Errorf("unterminated value of label %s", key)
+		}
+		labels[key] = value.String()
+		line = line[i+1:]
+	}
+}
+
+// family returns the name and type of the family of the sample called name, and the suffix of the name.
+// The samples without a type are untyped, their own family.
+func family(name string, types map[string]string) (string, string, string) {
+	if t, ok := types[name]; ok {
+		return name, t, ""
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		base := strings.TrimSuffix(name, suffix)
+		if base == name {
+			continue
+		}
+		if t := types[base]; t == typeHistogram || (t == typeSummary && suffix != "_bucket") {
+			return base, t, suffix
+		}
+	}
+	return name, typeUntyped, ""
+}
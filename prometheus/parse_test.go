@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	samples, err := parse(strings.NewReader(`
+# HELP http_requests_total The number of requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",path="/a \"quoted\"\\path\n"} 1027 1395066363000
+http_requests_total{method="get",} 3
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="+Inf"} 5
+latency_seconds_sum 1.5
+latency_seconds_count 5
+# TYPE rpc_seconds summary
+rpc_seconds{quantile="0.5"} NaN
+rpc_seconds_count 0
+# a comment
+temperature 21.5
+`))
+	require.NoError(t, err)
+	require.Len(t, samples, 9)
+
+	assert.Equal(t, sample{
+		family:     "http_requests_total",
+		familyType: typeCounter,
+		labels:     map[string]string{"method": "post", "path": "/a \"quoted\"\\path\n"},
+		value:      1027,
+		timestamp:  1395066363000,
+	}, samples[0])
+	assert.Equal(t, map[string]string{"method": "get"}, samples[1].labels)
+	assert.Equal(t, "latency_seconds", samples[3].family)
+	assert.Equal(t, "_bucket", samples[3].suffix)
+	assert.Equal(t, "+Inf", samples[3].labels["le"])
+	assert.Equal(t, "_count", samples[5].suffix)
+	assert.Equal(t, typeSummary, samples[6].familyType)
+	assert.True(t, math.IsNaN(samples[6].value))
+	assert.Equal(t, sample{family: "temperature", familyType: typeUntyped, labels: map[string]string{}, value: 21.5}, samples[8])
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, text := range []string{
+		`requests{method="get" 1`,
+		`requests{method=get} 1`,
+		`requests{method="get} 1`,
+		`requests`,
+		`requests one`,
+		`requests 1 2 3`,
+		`requests 1 now`,
+	} {
+		_, err := parse(strings.NewReader(text))
+		assert.Error(t, err, text)
+	}
+}
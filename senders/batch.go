@@ -0,0 +1,96 @@
+package senders
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BatchError is the error of the items of a batch that could not be sent.
+type BatchError struct {
+	// Errors are sorted by Index.
+	Errors []ItemError
+}
+
+// ItemError is the error of the item at Index in a batch.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	items := make([]string, len(e.Errors))
+	for i, itemErr := range e.Errors {
+		items[i] = fmt.Sprintf("item %d: %s", itemErr.Index, itemErr.Err)
+	}
+	return fmt.Sprintf("%d items failed: %s", len(e.Errors), strings.Join(items, ","))
+}
+
+// Is reports whether the error of any item matches target.
+func (e *BatchError) Is(target error) bool {
+	for _, itemErr := range e.Errors {
+		if errors.Is(itemErr.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of an item that matches target.
+func (e *BatchError) As(target interface{}) bool {
+	for _, itemErr := range e.Errors {
+		if errors.As(itemErr.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// add collects the error of the item at index, if not nil.
+func (e *BatchError) add(index int, err error) {
+	if err != nil {
+		e.Errors = append(e.Errors, ItemError{Index: index, Err: err})
+	}
+}
+
+func (e *BatchError) get() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(e.Errors, func(i, j int) bool {
+		return e.Errors[i].Index < e.Errors[j].Index
+	})
+	return e
+}
+
+// batchPart is the items of a batch sent by the same sender, along with their index in the batch.
+type batchPart[T any] struct {
+	items   []T
+	indices []int
+}
+
+func (p *batchPart[T]) add(index int, item T) {
+	p.items = append(p.items, item)
+	p.indices = append(p.indices, index)
+}
+
+// addErrors adds err, the error of sending the part, to the errors of the batch: the errors of
+// the items of a *BatchError, or err for every item. wrap names the sender of the part.
+// It returns the number of items that failed.
+func (p *batchPart[T]) addErrors(batchErr *BatchError, err error, wrap func(error) error) int {
+	var partErr *BatchError
+	if errors.As(err, &partErr) {
+		for _, itemErr := range partErr.Errors {
+			batchErr.add(p.indices[itemErr.Index], wrap(itemErr.Err))
+		}
+		return len(partErr.Errors)
+	}
+	if err == nil {
+		return 0
+	}
+	for _, index := range p.indices {
+		batchErr.add(index, wrap(err))
+	}
+	return len(p.indices)
+}
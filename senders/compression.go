@@ -0,0 +1,56 @@
+package senders
+
+import (
+	"compress/gzip"
+	"fmt"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// CompressionLevel is the level at which the body of HTTP requests is compressed,
+// from BestSpeed to BestCompression.
+type CompressionLevel int
+
+const (
+	// NoCompression sends requests uncompressed, which suits a proxy on the same host.
+	NoCompression CompressionLevel = gzip.NoCompression
+	// BestSpeed compresses requests as fast as possible.
+	BestSpeed CompressionLevel = gzip.BestSpeed
+	// BestCompression compresses requests as much as possible.
+	BestCompression CompressionLevel = gzip.BestCompression
+	// DefaultCompression compresses requests at the default level of gzip, and is the default.
+	DefaultCompression CompressionLevel = gzip.DefaultCompression
+)
+
+// Codec compresses the body of HTTP requests, and sets their Content-Encoding accordingly.
+type Codec = internal.Codec
+
+// GzipCodec returns a Codec compressing requests with gzip at level, which is the default.
+// It reuses its compressors across requests.
+func GzipCodec(level CompressionLevel) (Codec, error) {
+	if level == NoCompression {
+		return internal.NoCompression, nil
+	}
+	return internal.NewGzipCodec(int(level))
+}
+
+// DeflateCodec returns a Codec compressing requests with the deflate Content-Encoding at level.
+// It reuses its compressors across requests.
+func DeflateCodec(level CompressionLevel) (Codec, error) {
+	if level == NoCompression {
+		return internal.NoCompression, nil
+	}
+	return internal.NewDeflateCodec(int(level))
+}
+
+// codec returns the Codec of the HTTP requests sent by the sender.
+func (c *configuration) codec() (Codec, error) {
+	if c.Codec != nil {
+		return c.Codec, nil
+	}
+	codec, err := GzipCodec(c.CompressionLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compression level: %d", c.CompressionLevel)
+	}
+	return codec, nil
+}
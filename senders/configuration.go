@@ -3,12 +3,14 @@ package senders
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
 	"github.com/wavefronthq/wavefront-sdk-go/internal/auth"
 )
 
@@ -20,12 +22,30 @@ const (
 	defaultBufferSize    = 50_000
 	defaultFlushInterval = 1 * time.Second
 	defaultTimeout       = 10 * time.Second
+
+	transportHTTP = "http"
+	transportTCP  = "tcp"
+	transportUnix = "unix"
+	transportUDP  = "udp"
 )
 
 // Configuration for the direct ingestion sender
 type configuration struct {
 	Server string // Wavefront URL of the form https://<INSTANCE>.wavefront.com
 
+	// how data is sent: "http" for HTTP(S) requests, "tcp" and "unix" for the plaintext protocol
+	// over a persistent connection to a proxy, "udp" for the plaintext protocol over datagrams.
+	Transport string
+	host      string
+
+	// paths of the Unix domain sockets to send metrics and traces to, with the unix transport.
+	// traces are sent to the metrics socket unless TracesSocketPath is set.
+	MetricsSocketPath string
+	TracesSocketPath  string
+
+	// max size of the datagrams sent with the udp transport. defaults to 1,400 bytes.
+	MaxDatagramSize int
+
 	// Optional configuration properties. Default values should suffice for most use cases.
 	// override the defaults only if you wish to set higher values.
 
@@ -35,6 +55,14 @@ type configuration struct {
 	// max batch of data sent per flush interval. defaults to 10,000. recommended not to exceed 40,000.
 	BatchSize int
 
+	// max size in bytes of the uncompressed body of each request, on top of BatchSize. disabled when 0.
+	MaxBatchBytes int
+
+	// how the body of HTTP requests is compressed: with gzip at CompressionLevel,
+	// DefaultCompression by default, unless a Codec is set.
+	CompressionLevel CompressionLevel
+	Codec            Codec
+
 	// send, or don't send, internal SDK metrics that begin with ~sdk.go.core
 	SendInternalMetrics bool
 
@@ -47,7 +75,33 @@ type configuration struct {
 
 	// interval (in seconds) at which to flush data to Wavefront. defaults to 1 Second.
 	// together with batch size controls the max theoretical throughput of the sender.
-	FlushInterval           time.Duration
+	FlushInterval time.Duration
+
+	// directory in which lines that do not fit in the internal buffers, or fail to be reported,
+	// are persisted and from which they are replayed on start. disabled when empty.
+	PersistentBufferDir string
+
+	// max disk usage of the persistent buffer, per data type.
+	PersistentBufferMaxBytes int64
+
+	// how batches that fail to be reported are retried. when nil, their lines are buffered
+	// again and retried on the next flush.
+	RetryPolicy *internal.RetryPolicy
+
+	// consecutive failures after which reporting to an endpoint is suspended, and for how long.
+	// the circuit breaker is disabled when CircuitBreakerThreshold is 0.
+	CircuitBreakerThreshold   int
+	CircuitBreakerOpenTimeout time.Duration
+
+	// URLs of additional proxies data is sent to, on top of the one of the Sender URL, and
+	// how data is spread over them. their ports and path default to the ones of the Sender.
+	Endpoints      []string
+	EndpointPolicy LoadBalancingPolicy
+	endpoints      []endpoint
+
+	// settings overriding the defaults for a single data type.
+	DataTypes map[DataType]*dataTypeConfiguration
+
 	SDKMetricsTags          map[string]string
 	Path                    string
 	Authentication          interface{}
@@ -61,12 +115,16 @@ func (c *configuration) Direct() bool {
 
 func createConfig(wfURL string, setters ...Option) (*configuration, error) {
 	cfg := &configuration{
+		Transport:               transportHTTP,
 		MetricsPort:             defaultMetricsPort,
 		TracesPort:              defaultTracesPort,
 		BatchSize:               defaultBatchSize,
 		MaxBufferSize:           defaultBufferSize,
 		FlushInterval:           defaultFlushInterval,
+		CompressionLevel:        DefaultCompression,
+		MaxDatagramSize:         internal.DefaultMaxDatagramSize,
 		SendInternalMetrics:     true,
+		DataTypes:               map[DataType]*dataTypeConfiguration{},
 		SDKMetricsTags:          map[string]string{},
 		httpClientConfiguration: &httpClientConfiguration{Timeout: defaultTimeout},
 	}
@@ -87,6 +145,35 @@ func createConfig(wfURL string, setters ...Option) (*configuration, error) {
 		set(cfg)
 	}
 
+	if cfg.PersistentBufferDir != "" {
+		if cfg.PersistentBufferMaxBytes <= 0 {
+			return nil, fmt.Errorf("invalid persistent buffer size: %d", cfg.PersistentBufferMaxBytes)
+		}
+	}
+
+	if cfg.MaxBatchBytes < 0 {
+		return nil, fmt.Errorf("invalid max batch bytes: %d", cfg.MaxBatchBytes)
+	}
+
+	if cfg.Codec, err = cfg.codec(); err != nil {
+		return nil, err
+	}
+
+	for dataType, typeCfg := range cfg.DataTypes {
+		if err := cfg.validateDataType(dataType, typeCfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateRetryPolicy(cfg.RetryPolicy); err != nil {
+		return nil, err
+	}
+
+	if cfg.CircuitBreakerThreshold < 0 || (cfg.CircuitBreakerThreshold > 0 && cfg.CircuitBreakerOpenTimeout <= 0) {
+		return nil, fmt.Errorf("invalid circuit breaker: threshold=%d open timeout=%v",
+			cfg.CircuitBreakerThreshold, cfg.CircuitBreakerOpenTimeout)
+	}
+
 	switch strings.ToLower(u.Scheme) {
 	case "http":
 		if cfg.Direct() {
@@ -98,8 +185,35 @@ func createConfig(wfURL string, setters ...Option) (*configuration, error) {
 			log.Println("Detecting wavefront direct ingestion, will attempt to connect port 443.")
 			cfg.setDefaultPort(443)
 		}
+	case "tcp":
+		if cfg.Direct() {
+			return nil, fmt.Errorf("direct ingestion is not supported over tcp, use https instead")
+		}
+		cfg.Transport = transportTCP
+	case "unix":
+		if cfg.Direct() {
+			return nil, fmt.Errorf("direct ingestion is not supported over unix sockets, use https instead")
+		}
+		if u.Path == "" {
+			return nil, fmt.Errorf("missing socket path in '%s'", u)
+		}
+		cfg.Transport = transportUnix
+		cfg.MetricsSocketPath = u.Path
+		if cfg.TracesSocketPath == "" {
+			cfg.TracesSocketPath = u.Path
+		}
+		u.Path = ""
+	case "udp":
+		if cfg.Direct() {
+			return nil, fmt.Errorf("direct ingestion is not supported over udp, use https instead")
+		}
+		if cfg.MaxDatagramSize <= 0 || cfg.MaxDatagramSize > internal.MaxUDPPayloadSize {
+			return nil, fmt.Errorf("invalid max datagram size: %d, must be between 1 and %d",
+				cfg.MaxDatagramSize, internal.MaxUDPPayloadSize)
+		}
+		cfg.Transport = transportUDP
 	default:
-		return nil, fmt.Errorf("invalid scheme '%s' in '%s', only 'http/https' is supported", u.Scheme, u)
+		return nil, fmt.Errorf("invalid scheme '%s' in '%s', only 'http/https/tcp/unix/udp' is supported", u.Scheme, u)
 	}
 
 	if u.Path != "" {
@@ -112,11 +226,28 @@ func createConfig(wfURL string, setters ...Option) (*configuration, error) {
 		if err != nil {
 			return nil, fmt.Errorf("unable to convert port to integer: %s", err)
 		}
-		cfg.setDefaultPort(port)
+		if cfg.Transport == transportTCP || cfg.Transport == transportUDP {
+			// the plaintext listeners for metrics and traces are always distinct.
+			cfg.MetricsPort = port
+		} else {
+			cfg.setDefaultPort(port)
+		}
 		u.Host = u.Hostname()
 	}
+	cfg.host = u.Hostname()
 	cfg.Server = u.String()
 
+	if cfg.EndpointPolicy < RoundRobin || cfg.EndpointPolicy > Failover {
+		return nil, fmt.Errorf("invalid load balancing policy: %d", cfg.EndpointPolicy)
+	}
+	for _, rawURL := range cfg.Endpoints {
+		e, err := cfg.parseEndpoint(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		cfg.endpoints = append(cfg.endpoints, e)
+	}
+
 	if cfg.HTTPClient == nil {
 		cfg.HTTPClient = &http.Client{
 			Timeout: cfg.httpClientConfiguration.Timeout,
@@ -129,6 +260,124 @@ func createConfig(wfURL string, setters ...Option) (*configuration, error) {
 	return cfg, nil
 }
 
+func validateRetryPolicy(policy *internal.RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.InitialBackoff <= 0 || policy.MaxBackoff < policy.InitialBackoff {
+		return fmt.Errorf("invalid retry backoff: initial=%v max=%v", policy.InitialBackoff, policy.MaxBackoff)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return fmt.Errorf("invalid retry jitter: %v, must be between 0 and 1", policy.Jitter)
+	}
+	if policy.MaxAttempts < 0 {
+		return fmt.Errorf("invalid retry max attempts: %d", policy.MaxAttempts)
+	}
+	return nil
+}
+
+func (c *configuration) validateDataType(dataType DataType, typeCfg *dataTypeConfiguration) error {
+	if typeCfg.BatchSize < 0 || typeCfg.FlushInterval < 0 || typeCfg.MaxBufferSize < 0 {
+		return fmt.Errorf("invalid settings for %s: batch size=%d flush interval=%v max buffer size=%d",
+			dataType, typeCfg.BatchSize, typeCfg.FlushInterval, typeCfg.MaxBufferSize)
+	}
+	if typeCfg.FlushWorkers < 0 {
+		return fmt.Errorf("invalid number of flush workers for %s: %d", dataType, typeCfg.FlushWorkers)
+	}
+	if typeCfg.AdaptiveFlush && (typeCfg.LowWaterMark < 0 || typeCfg.FlushBudget < 0) {
+		return fmt.Errorf("invalid adaptive flush for %s: low-water mark=%d budget=%v",
+			dataType, typeCfg.LowWaterMark, typeCfg.FlushBudget)
+	}
+	if dataType == EventData && typeCfg.BatchSize > 1 {
+		return fmt.Errorf("invalid batch size for %s: %d, events are always sent one at a time", dataType, typeCfg.BatchSize)
+	}
+	if (typeCfg.BatchSize > 0 || typeCfg.MaxBufferSize > 0) && c.batchSize(dataType) > c.maxBufferSize(dataType) {
+		return fmt.Errorf("incompatible settings for %s: batch size %d exceeds max buffer size %d",
+			dataType, c.batchSize(dataType), c.maxBufferSize(dataType))
+	}
+	if typeCfg.OverflowPolicy != nil {
+		if *typeCfg.OverflowPolicy == SpillToDisk && c.PersistentBufferDir == "" {
+			return fmt.Errorf("invalid overflow policy for %s: %s requires a persistent buffer", dataType, SpillToDisk)
+		}
+		if typeCfg.BlockTimeout < 0 {
+			return fmt.Errorf("invalid block timeout for %s: %v", dataType, typeCfg.BlockTimeout)
+		}
+	}
+	if typeCfg.RateLimit < 0 || typeCfg.RateLimitBurst < 0 {
+		return fmt.Errorf("invalid rate limit for %s: %v lines/s, burst %d", dataType, typeCfg.RateLimit, typeCfg.RateLimitBurst)
+	}
+	return nil
+}
+
+// dataType returns the settings of dataType, creating them if needed.
+func (c *configuration) dataType(dataType DataType) *dataTypeConfiguration {
+	typeCfg, ok := c.DataTypes[dataType]
+	if !ok {
+		typeCfg = &dataTypeConfiguration{}
+		c.DataTypes[dataType] = typeCfg
+	}
+	return typeCfg
+}
+
+func (c *configuration) batchSize(dataType DataType) int {
+	if dataType == EventData {
+		return 1
+	}
+	if typeCfg := c.DataTypes[dataType]; typeCfg != nil && typeCfg.BatchSize > 0 {
+		return typeCfg.BatchSize
+	}
+	return c.BatchSize
+}
+
+func (c *configuration) maxBufferSize(dataType DataType) int {
+	if typeCfg := c.DataTypes[dataType]; typeCfg != nil && typeCfg.MaxBufferSize > 0 {
+		return typeCfg.MaxBufferSize
+	}
+	return c.MaxBufferSize
+}
+
+// dataTypeOptions returns the options of the line handler of dataType.
+func (c *configuration) dataTypeOptions(dataType DataType) []internal.LineHandlerOption {
+	var options []internal.LineHandlerOption
+	typeCfg := c.dataType(dataType)
+	if typeCfg.FlushInterval > 0 {
+		options = append(options, internal.SetFlushInterval(typeCfg.FlushInterval))
+	}
+	if typeCfg.MaxBufferSize > 0 {
+		options = append(options, internal.SetMaxBufferSize(typeCfg.MaxBufferSize))
+	}
+	if typeCfg.FlushWorkers > 0 {
+		options = append(options, internal.SetFlushWorkers(typeCfg.FlushWorkers))
+	}
+	if typeCfg.AdaptiveFlush {
+		options = append(options, internal.SetAdaptiveFlush(typeCfg.LowWaterMark, typeCfg.FlushBudget))
+	}
+	switch {
+	case typeCfg.OverflowPolicy != nil:
+		options = append(options, internal.SetOverflowPolicy(*typeCfg.OverflowPolicy, typeCfg.BlockTimeout))
+	case c.PersistentBufferDir != "":
+		options = append(options, internal.SetOverflowPolicy(SpillToDisk, 0))
+	}
+	if typeCfg.RateLimit > 0 {
+		options = append(options, internal.SetRateLimit(typeCfg.RateLimit, typeCfg.RateLimitBurst, typeCfg.RateLimitMode))
+	}
+	return options
+}
+
+func (c *configuration) lineHandlerOptions() []internal.LineHandlerOption {
+	var options []internal.LineHandlerOption
+	if c.PersistentBufferDir != "" {
+		options = append(options, internal.SetPersistentBuffer(c.PersistentBufferDir, c.PersistentBufferMaxBytes))
+	}
+	if c.RetryPolicy != nil {
+		options = append(options, internal.SetRetryPolicy(*c.RetryPolicy))
+	}
+	if c.MaxBatchBytes > 0 {
+		options = append(options, internal.SetMaxBatchBytes(c.MaxBatchBytes))
+	}
+	return options
+}
+
 func (c *configuration) setDefaultPort(port int) {
 	c.MetricsPort = port
 	c.TracesPort = port
@@ -142,6 +391,14 @@ func (c *configuration) metricsURL() string {
 	return fmt.Sprintf("%s:%d%s", c.Server, c.MetricsPort, c.Path)
 }
 
+func (c *configuration) tracesAddress() string {
+	return net.JoinHostPort(c.host, strconv.Itoa(c.TracesPort))
+}
+
+func (c *configuration) metricsAddress() string {
+	return net.JoinHostPort(c.host, strconv.Itoa(c.MetricsPort))
+}
+
 func (c *configuration) MetricPrefix() string {
 	result := "~sdk.go.core.sender.proxy"
 	if c.Direct() {
@@ -0,0 +1,88 @@
+package senders
+
+import (
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+)
+
+// DataType identifies one of the kinds of data a Sender sends, each of which is
+// buffered and flushed separately.
+type DataType int
+
+const (
+	PointData DataType = iota
+	HistogramData
+	SpanData
+	SpanLogData
+	EventData
+)
+
+// AllDataTypes lists every DataType.
+var AllDataTypes = []DataType{PointData, HistogramData, SpanData, SpanLogData, EventData}
+
+func (t DataType) String() string {
+	switch t {
+	case PointData:
+		return "points"
+	case HistogramData:
+		return "histograms"
+	case SpanData:
+		return "spans"
+	case SpanLogData:
+		return "span_logs"
+	case EventData:
+		return "events"
+	default:
+		return "unknown"
+	}
+}
+
+// OverflowPolicy decides what happens to data sent while the buffer of its DataType is full.
+type OverflowPolicy = internal.OverflowPolicy
+
+const (
+	// DropNewest drops the data being sent, and is the default without a persistent buffer.
+	DropNewest = internal.OverflowDropNewest
+	// DropOldest evicts the oldest buffered data to make room for the data being sent.
+	DropOldest = internal.OverflowDropOldest
+	// Block blocks the caller until there is room in the buffer, or drops the data
+	// once the block timeout has elapsed.
+	Block = internal.OverflowBlock
+	// SpillToDisk writes the data to the persistent buffer, and is the default with a persistent buffer.
+	SpillToDisk = internal.OverflowSpillToDisk
+)
+
+// RateLimitMode decides what happens to data of a DataType sent faster than its rate limit.
+type RateLimitMode = internal.RateLimitMode
+
+const (
+	// QueueExcess keeps the excess data buffered until the rate limit lets it through.
+	QueueExcess = internal.RateLimitQueue
+	// DropExcess drops the excess data as it is sent.
+	DropExcess = internal.RateLimitDrop
+)
+
+// dataTypeConfiguration holds the settings of a single DataType.
+type dataTypeConfiguration struct {
+	// override the sender-wide BatchSize, FlushInterval and MaxBufferSize when not 0.
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxBufferSize int
+
+	// number of batches reported concurrently per flush interval, 1 when 0.
+	FlushWorkers int
+
+	// keep flushing until LowWaterMark lines are buffered, for up to FlushBudget.
+	AdaptiveFlush bool
+	LowWaterMark  int
+	FlushBudget   time.Duration
+
+	OverflowPolicy *OverflowPolicy
+	BlockTimeout   time.Duration
+
+	// max lines per second reported, disabled when 0.
+	RateLimit      float64
+	RateLimitBurst int
+	RateLimitMode  RateLimitMode
+}
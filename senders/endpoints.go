@@ -0,0 +1,132 @@
+package senders
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
+	"github.com/wavefronthq/wavefront-sdk-go/internal/sdkmetrics"
+)
+
+// LoadBalancingPolicy decides which of the endpoints of a Sender data is sent to first.
+// Data that fails to be sent to an endpoint is sent to the next one.
+type LoadBalancingPolicy = internal.LoadBalancingPolicy
+
+const (
+	// RoundRobin spreads data evenly over the healthy endpoints.
+	RoundRobin = internal.RoundRobin
+	// LeastFailures sends data to the healthy endpoint with the fewest recent failures.
+	LeastFailures = internal.LeastFailures
+	// Failover sends data to the first healthy endpoint, the URL of the Sender being the primary one.
+	Failover = internal.Failover
+)
+
+const (
+	defaultEndpointFailureThreshold = 3
+	defaultEndpointRetryInterval    = 30 * time.Second
+)
+
+// endpoint is where metrics and traces are sent: URLs with http and https, addresses
+// with tcp and udp, and socket paths with unix.
+type endpoint struct {
+	metrics string
+	traces  string
+}
+
+// mainEndpoint returns the endpoint of the URL of the Sender.
+func (c *configuration) mainEndpoint() endpoint {
+	switch c.Transport {
+	case transportTCP, transportUDP:
+		return endpoint{metrics: c.metricsAddress(), traces: c.tracesAddress()}
+	case transportUnix:
+		return endpoint{metrics: c.MetricsSocketPath, traces: c.TracesSocketPath}
+	default:
+		return endpoint{metrics: c.metricsURL(), traces: c.tracesURL()}
+	}
+}
+
+// parseEndpoint parses an additional endpoint, which must use the transport of the URL of the Sender.
+// Its ports and path default to the ones of the Sender.
+func (c *configuration) parseEndpoint(rawURL string) (endpoint, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return endpoint{}, err
+	}
+	if u.User != nil {
+		return endpoint{}, fmt.Errorf("endpoint '%s' must not hold credentials, the ones of the sender URL are used", u.Redacted())
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if transport := transportOf(scheme); transport != c.Transport {
+		return endpoint{}, fmt.Errorf("endpoint '%s' does not use the %s transport of the sender URL", u, c.Transport)
+	}
+	if scheme == "unix" {
+		if u.Path == "" {
+			return endpoint{}, fmt.Errorf("missing socket path in '%s'", u)
+		}
+		return endpoint{metrics: u.Path, traces: u.Path}, nil
+	}
+
+	metricsPort, tracesPort := c.MetricsPort, c.TracesPort
+	if u.Port() != "" {
+		port, err := strconv.Atoi(u.Port())
+		if err != nil {
+			return endpoint{}, fmt.Errorf("unable to convert port to integer: %s", err)
+		}
+		metricsPort = port
+		if c.Transport == transportHTTP {
+			tracesPort = port
+		}
+	}
+	if c.Transport != transportHTTP {
+		return endpoint{
+			metrics: net.JoinHostPort(u.Hostname(), strconv.Itoa(metricsPort)),
+			traces:  net.JoinHostPort(u.Hostname(), strconv.Itoa(tracesPort)),
+		}, nil
+	}
+	path := u.Path
+	if path == "" {
+		path = c.Path
+	}
+	server := scheme + "://" + u.Hostname()
+	return endpoint{
+		metrics: fmt.Sprintf("%s:%d%s", server, metricsPort, path),
+		traces:  fmt.Sprintf("%s:%d%s", server, tracesPort, path),
+	}, nil
+}
+
+func transportOf(scheme string) string {
+	switch scheme {
+	case "http", "https":
+		return transportHTTP
+	default:
+		return scheme
+	}
+}
+
+// newEndpointPool spreads reports over reporters, which report to endpoints, according to the
+// policy of the Sender. The state of the health of each endpoint is reported as an internal metric.
+func newEndpointPool(
+	name string,
+	endpoints []string,
+	reporters []internal.Reporter,
+	cfg *configuration,
+	registry sdkmetrics.Registry,
+) internal.Reporter {
+	threshold, retryInterval := cfg.CircuitBreakerThreshold, cfg.CircuitBreakerOpenTimeout
+	if threshold == 0 {
+		threshold, retryInterval = defaultEndpointFailureThreshold, defaultEndpointRetryInterval
+	}
+	breakers := make([]*internal.CircuitBreaker, len(reporters))
+	for i, reporter := range reporters {
+		breaker := internal.NewCircuitBreaker(endpoints[i], reporter, threshold, retryInterval)
+		registry.NewGauge(fmt.Sprintf("endpoints.%s.%d.state", name, i), func() int64 {
+			return int64(breaker.State())
+		})
+		breakers[i] = breaker
+	}
+	return internal.NewEndpointPool(cfg.EndpointPolicy, breakers...)
+}
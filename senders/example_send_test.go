@@ -1,6 +1,9 @@
 package senders_test
 
 import (
+	"context"
+	"errors"
+
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 	wavefront "github.com/wavefronthq/wavefront-sdk-go/senders"
 )
@@ -83,3 +86,40 @@ func Example_sender() {
 	}
 	sender.Close()
 }
+
+func Example_batch() {
+	sender, err := wavefront.NewSender("http://localhost")
+	if err != nil {
+		// handle error
+	}
+
+	// A batch is formatted and buffered at once. When an item is invalid, none are sent.
+	err = sender.SendPoints(context.Background(), []wavefront.Point{
+		{Name: "new-york.power.usage", Value: 42422.0, Source: "go_test", Tags: map[string]string{"env": "test"}},
+		{Name: "new-york.power.capacity", Value: 50000.0, Source: "go_test", Tags: map[string]string{"env": "test"}},
+	})
+	var batchErr *wavefront.BatchError
+	if errors.As(err, &batchErr) {
+		for _, itemErr := range batchErr.Errors {
+			// handle the error of the point at itemErr.Index
+			_ = itemErr
+		}
+	}
+
+	err = sender.SendSpans(context.Background(), []wavefront.Span{
+		{
+			Name:           "getAllUsers",
+			StartMillis:    1552949776000,
+			DurationMillis: 343,
+			Source:         "localhost",
+			TraceID:        "7b3bf470-9456-11e8-9eb6-529269fb1459",
+			SpanID:         "0313bafe-9457-11e8-9eb6-529269fb1459",
+			Tags:           []wavefront.SpanTag{{Key: "application", Value: "Wavefront"}},
+		},
+	})
+	if err != nil {
+		// handle err
+	}
+
+	sender.Close()
+}
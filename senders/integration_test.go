@@ -1,6 +1,10 @@
 package senders
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -24,6 +28,26 @@ func TestEndToEnd(t *testing.T) {
 	assert.Equal(t, "/api/v2/event", testServer.RequestURLs[1])
 }
 
+func TestEndToEndFlushContext(t *testing.T) {
+	testServer := startTestServer(false)
+	defer testServer.Close()
+	sender, err := NewSender(testServer.URL, SendInternalMetrics(false), FlushInterval(time.Hour))
+	require.NoError(t, err)
+	defer sender.Close()
+	require.NoError(t, sender.SendMetricContext(context.Background(), "my metric", 20, 0, "localhost", nil))
+
+	testServer.delay.Store(int64(time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = sender.FlushContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, testServer.MetricLines)
+
+	testServer.delay.Store(0)
+	require.NoError(t, sender.FlushContext(context.Background()))
+	assert.Equal(t, []string{"\"my-metric\" 20 source=\"localhost\""}, testServer.MetricLines)
+}
+
 func TestEndToEndWithPath(t *testing.T) {
 	testServer := startTestServer(false)
 	defer testServer.Close()
@@ -101,3 +125,136 @@ func TestEndToEndDirect(t *testing.T) {
 	assert.Equal(t, "/report?f=wavefront", testServer.RequestURLs[0])
 	assert.Equal(t, "/api/v2/event", testServer.RequestURLs[1])
 }
+
+func TestEndToEndTCP(t *testing.T) {
+	metricsServer, err := startTestSocketServer("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer metricsServer.Close()
+	tracesServer, err := startTestSocketServer("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tracesServer.Close()
+
+	sender, err := NewSender(fmt.Sprintf("tcp://127.0.0.1:%d", metricsServer.Port()),
+		TracesPort(tracesServer.Port()), SendInternalMetrics(false))
+	require.NoError(t, err)
+	require.NoError(t, sender.SendMetric("my metric", 20, 0, "localhost", nil))
+	require.NoError(t, sender.SendEvent("dramatic event", 20, 0, "localhost", nil))
+	require.NoError(t, sender.SendSpan("my span", 0, 10, "localhost",
+		"7b3bf470-9456-11e8-9eb6-529269fb1459", "0313bafe-9457-11e8-9eb6-529269fb1459",
+		nil, nil, nil, nil))
+	require.NoError(t, sender.Flush())
+	sender.Close()
+
+	assert.Equal(t, "\"my-metric\" 20 source=\"localhost\"", metricsServer.nextLine())
+	assert.Equal(t, "@Event 20000 20001 \"dramatic event\" host=\"localhost\"", metricsServer.nextLine())
+	assert.Equal(t, "\"my span\" source=\"localhost\" traceId=7b3bf470-9456-11e8-9eb6-529269fb1459 spanId=0313bafe-9457-11e8-9eb6-529269fb1459 0 10", tracesServer.nextLine())
+	assert.Equal(t, int64(0), sender.GetFailureCount())
+}
+
+func TestEndToEndShutdown(t *testing.T) {
+	testServer := startTestServer(false)
+	defer testServer.Close()
+	sender, err := NewSender(testServer.URL, SendInternalMetrics(false), FlushInterval(time.Hour), BatchSize(1))
+	require.NoError(t, err)
+	require.NoError(t, sender.SendMetric("my metric", 20, 0, "localhost", nil))
+	require.NoError(t, sender.SendEvent("dramatic event", 20, 0, "localhost", nil))
+
+	result, err := sender.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, DrainStats{Delivered: 1}, result.DataTypes[PointData])
+	assert.Equal(t, DrainStats{Delivered: 1}, result.DataTypes[EventData])
+	assert.Equal(t, DrainStats{Delivered: 2}, result.Total())
+}
+
+func TestEndToEndShutdownThenClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	sender, err := NewSender(server.URL, PersistentBuffer(t.TempDir(), 1<<20), FlushInterval(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, sender.SendMetric("my metric", 20, 0, "localhost", nil))
+
+	result, err := sender.Shutdown(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, DrainStats{Persisted: 1}, result.DataTypes[PointData])
+
+	closed := make(chan struct{})
+	go func() {
+		sender.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after Shutdown")
+	}
+}
+
+func TestEndToEndShutdownDeadline(t *testing.T) {
+	testServer := startTestServer(false)
+	defer testServer.Close()
+	sender, err := NewSender(testServer.URL, SendInternalMetrics(false), FlushInterval(time.Hour), BatchSize(1))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sender.SendMetric("my metric", float64(i), 0, "localhost", nil))
+	}
+	testServer.delay.Store(int64(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := sender.Shutdown(ctx)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, DrainStats{Lost: 3}, result.Total())
+}
+
+func TestEndToEndCompression(t *testing.T) {
+	deflate, err := DeflateCodec(BestSpeed)
+	require.NoError(t, err)
+	for _, test := range []struct {
+		option   Option
+		encoding string
+	}{
+		{Compression(DefaultCompression), "gzip"},
+		{Compression(BestCompression), "gzip"},
+		{Compression(NoCompression), ""},
+		{CompressionCodec(deflate), "deflate"},
+	} {
+		testServer := startTestServer(false)
+		sender, err := NewSender(testServer.URL, test.option, SendInternalMetrics(false))
+		require.NoError(t, err)
+		require.NoError(t, sender.SendMetric("my metric", 20, 0, "localhost", nil))
+		require.NoError(t, sender.Flush())
+		sender.Close()
+		testServer.Close()
+
+		assert.Equal(t, []string{test.encoding}, testServer.Encodings)
+		assert.Equal(t, []string{"\"my-metric\" 20 source=\"localhost\""}, testServer.MetricLines)
+	}
+
+	_, err = NewSender("http://localhost", Compression(10))
+	assert.Error(t, err)
+}
+
+func TestEndToEndFailover(t *testing.T) {
+	primary := startTestServer(false)
+	primary.Close()
+	backup := startTestServer(false)
+	defer backup.Close()
+
+	sender, err := NewSender(primary.URL, Endpoints(Failover, backup.URL), CircuitBreaker(1, time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, sender.SendMetric("my metric", 20, 0, "localhost", nil))
+	require.NoError(t, sender.Flush())
+	require.NoError(t, sender.SendMetric("my metric", 21, 0, "localhost", nil))
+	require.NoError(t, sender.Flush())
+	sender.Close()
+
+	assert.Equal(t, []string{
+		"\"my-metric\" 20 source=\"localhost\"",
+		"\"my-metric\" 21 source=\"localhost\"",
+	}, backup.MetricLines)
+	assert.Empty(t, primary.MetricLines)
+}
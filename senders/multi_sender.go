@@ -1,8 +1,11 @@
 package senders
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
@@ -17,6 +20,88 @@ type multiSender struct {
 	senders []Sender
 }
 
+// DestinationError is an error of one of the senders of a MultiSender.
+type DestinationError struct {
+	// Destination is the name of the sender, set with Named, or where it sends data to.
+	Destination string
+	Err         error
+}
+
+func (e *DestinationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Destination, e.Err)
+}
+
+func (e *DestinationError) Unwrap() error {
+	return e.Err
+}
+
+// destination is a Sender given a name, or whose errors are not returned, by Named and BestEffort.
+type destination struct {
+	Sender
+	name       string
+	bestEffort bool
+}
+
+// Named names sender in the errors of the MultiSender it is one of the senders of.
+// Senders created with NewSender are named after the URL they send data to by default.
+func Named(name string, sender Sender) Sender {
+	d := asDestination(sender)
+	d.name = name
+	return d
+}
+
+// BestEffort makes the MultiSender sender is one of the senders of ignore its errors,
+// instead of returning them to the caller.
+func BestEffort(sender Sender) Sender {
+	d := asDestination(sender)
+	d.bestEffort = true
+	return d
+}
+
+func asDestination(sender Sender) *destination {
+	if d, ok := sender.(*destination); ok {
+		copied := *d
+		return &copied
+	}
+	return &destination{Sender: sender}
+}
+
+// unwrapDestination returns the Sender given a name, or made best effort.
+func unwrapDestination(sender Sender) Sender {
+	if d, ok := sender.(*destination); ok {
+		return d.Sender
+	}
+	return sender
+}
+
+// destinationName returns the name of sender, given with Named, or else the URL it sends data to.
+// It is empty for other senders.
+func destinationName(sender Sender) string {
+	if d, ok := sender.(*destination); ok && d.name != "" {
+		return d.name
+	}
+	if rs, ok := unwrapDestination(sender).(*realSender); ok {
+		return rs.destination
+	}
+	return ""
+}
+
+// destinationError returns err as a DestinationError of sender, or nil if sender is best effort.
+// Senders without a destinationName are named defaultName.
+func destinationError(sender Sender, defaultName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if d, ok := sender.(*destination); ok && d.bestEffort {
+		return nil
+	}
+	name := destinationName(sender)
+	if name == "" {
+		name = defaultName
+	}
+	return &DestinationError{Destination: name, Err: err}
+}
+
 type multiError struct {
 	errors []error
 }
@@ -36,8 +121,33 @@ func (m *multiError) Error() string {
 	}
 }
 
+// add collects the errors that are not nil.
 func (m *multiError) add(es ...error) {
-	m.errors = append(m.errors, es...)
+	for _, err := range es {
+		if err != nil {
+			m.errors = append(m.errors, err)
+		}
+	}
+}
+
+// Is reports whether any of the collected errors matches target.
+func (m *multiError) Is(target error) bool {
+	for _, err := range m.errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first collected error that matches target.
+func (m *multiError) As(target interface{}) bool {
+	for _, err := range m.errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
 func (m *multiError) get() error {
@@ -47,7 +157,9 @@ func (m *multiError) get() error {
 	return nil
 }
 
-// NewMultiSender creates a new Wavefront MultiClient
+// NewMultiSender creates a new Wavefront MultiClient.
+// Data is sent to each of senders in turn, while they are flushed and closed concurrently.
+// Their errors are returned as DestinationErrors, unless they are BestEffort.
 func NewMultiSender(senders ...Sender) MultiSender {
 	ms := &multiSender{}
 	ms.senders = append(ms.senders, senders...)
@@ -58,69 +170,87 @@ func (ms *multiSender) private() {
 }
 
 func (ms *multiSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
-	var errors multiError
-	for _, sender := range ms.senders {
-		err := sender.SendMetric(name, value, ts, source, tags)
-		if err != nil {
-			errors.add(err)
-		}
-	}
-	return errors.get()
+	return ms.SendMetricContext(context.Background(), name, value, ts, source, tags)
+}
+
+func (ms *multiSender) SendMetricContext(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendMetricContext(ctx, name, value, ts, source, tags)
+	})
 }
 
 func (ms *multiSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
-	var errors multiError
-	for _, sender := range ms.senders {
-		err := sender.SendDeltaCounter(name, value, source, tags)
-		if err != nil {
-			errors.add(err)
-		}
-	}
-	return errors.get()
+	return ms.SendDeltaCounterContext(context.Background(), name, value, source, tags)
+}
+
+func (ms *multiSender) SendDeltaCounterContext(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendDeltaCounterContext(ctx, name, value, source, tags)
+	})
 }
 
 func (ms *multiSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
-	var errors multiError
-	for _, sender := range ms.senders {
-		err := sender.SendDistribution(name, centroids, hgs, ts, source, tags)
-		if err != nil {
-			errors.add(err)
-		}
-	}
-	return errors.get()
+	return ms.SendDistributionContext(context.Background(), name, centroids, hgs, ts, source, tags)
+}
+
+func (ms *multiSender) SendDistributionContext(ctx context.Context, name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendDistributionContext(ctx, name, centroids, hgs, ts, source, tags)
+	})
 }
 
 func (ms *multiSender) SendSpan(name string, startMillis, durationMillis int64, source, traceID, spanID string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
-	var errors multiError
-	for _, sender := range ms.senders {
-		err := sender.SendSpan(name, startMillis, durationMillis, source, traceID, spanID, parents, followsFrom, tags, spanLogs)
-		if err != nil {
-			errors.add(err)
-		}
-	}
-	return errors.get()
+	return ms.SendSpanContext(context.Background(), name, startMillis, durationMillis, source, traceID, spanID, parents, followsFrom, tags, spanLogs)
+}
+
+func (ms *multiSender) SendSpanContext(ctx context.Context, name string, startMillis, durationMillis int64, source, traceID, spanID string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendSpanContext(ctx, name, startMillis, durationMillis, source, traceID, spanID, parents, followsFrom, tags, spanLogs)
+	})
 }
 
 func (ms *multiSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
-	var errors multiError
-	for _, sender := range ms.senders {
-		err := sender.SendEvent(name, startMillis, endMillis, source, tags, setters...)
-		if err != nil {
-			errors.add(err)
-		}
-	}
-	return errors.get()
+	return ms.SendEventContext(context.Background(), name, startMillis, endMillis, source, tags, setters...)
+}
+
+func (ms *multiSender) SendEventContext(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendEventContext(ctx, name, startMillis, endMillis, source, tags, setters...)
+	})
+}
+
+func (ms *multiSender) SendPoints(ctx context.Context, points []Point) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendPoints(ctx, points)
+	})
+}
+
+func (ms *multiSender) SendDistributions(ctx context.Context, distributions []Distribution) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendDistributions(ctx, distributions)
+	})
+}
+
+func (ms *multiSender) SendSpans(ctx context.Context, spans []Span) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendSpans(ctx, spans)
+	})
+}
+
+func (ms *multiSender) SendEvents(ctx context.Context, events []Event) error {
+	return ms.each(func(sender Sender) error {
+		return sender.SendEvents(ctx, events)
+	})
 }
 
 func (ms *multiSender) Flush() error {
-	var errors multiError
-	for _, sender := range ms.senders {
-		err := sender.Flush()
-		if err != nil {
-			errors.add(err)
-		}
-	}
-	return errors.get()
+	return ms.FlushContext(context.Background())
+}
+
+func (ms *multiSender) FlushContext(ctx context.Context) error {
+	return ms.concurrently(func(sender Sender) error {
+		return sender.FlushContext(ctx)
+	})
 }
 
 func (ms *multiSender) GetFailureCount() int64 {
@@ -138,7 +268,59 @@ func (ms *multiSender) Start() {
 }
 
 func (ms *multiSender) Close() {
-	for _, sender := range ms.senders {
+	_ = ms.concurrently(func(sender Sender) error {
 		sender.Close()
+		return nil
+	})
+}
+
+func (ms *multiSender) CloseContext(ctx context.Context) error {
+	return ms.concurrently(func(sender Sender) error {
+		return sender.CloseContext(ctx)
+	})
+}
+
+// Shutdown shuts down every sender in parallel and sums their results.
+func (ms *multiSender) Shutdown(ctx context.Context) (ShutdownResult, error) {
+	var result ShutdownResult
+	var mtx sync.Mutex
+	err := ms.concurrently(func(sender Sender) error {
+		senderResult, err := sender.Shutdown(ctx)
+		mtx.Lock()
+		defer mtx.Unlock()
+		result.merge(senderResult)
+		return err
+	})
+	return result, err
+}
+
+// each calls f with each sender in turn, and returns their errors.
+func (ms *multiSender) each(f func(Sender) error) error {
+	var errors multiError
+	for i, sender := range ms.senders {
+		errors.add(ms.destinationError(i, f(sender)))
 	}
+	return errors.get()
+}
+
+// concurrently calls f with every sender at once, so that a slow sender does not hold up
+// the others, and returns their errors in the order of the senders.
+func (ms *multiSender) concurrently(f func(Sender) error) error {
+	errs := make([]error, len(ms.senders))
+	var wg sync.WaitGroup
+	for i, sender := range ms.senders {
+		wg.Add(1)
+		go func(i int, sender Sender) {
+			defer wg.Done()
+			errs[i] = ms.destinationError(i, f(sender))
+		}(i, sender)
+	}
+	wg.Wait()
+	var errors multiError
+	errors.add(errs...)
+	return errors.get()
+}
+
+func (ms *multiSender) destinationError(i int, err error) error {
+	return destinationError(ms.senders[i], fmt.Sprintf("sender %d", i), err)
 }
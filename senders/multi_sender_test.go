@@ -0,0 +1,110 @@
+package senders
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiSender_Context(t *testing.T) {
+	pointHandler := &mockHandler{}
+	wfSender := &realSender{
+		defaultSource:    "test",
+		pointHandler:     pointHandler,
+		histoHandler:     &mockHandler{},
+		spanHandler:      &mockHandler{},
+		spanLogHandler:   &mockHandler{},
+		eventHandler:     &mockHandler{},
+		internalRegistry: &mockRegistry{},
+	}
+	noop, _ := NewWavefrontNoOpClient()
+	sender := NewMultiSender(wfSender, noop)
+
+	require.NoError(t, sender.SendMetricContext(context.Background(), "foo", 20, 0, "test", nil))
+	assert.Equal(t, []string{"\"foo\" 20 source=\"test\"\n"}, pointHandler.Lines)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sender.SendMetricContext(ctx, "foo", 21, 0, "test", nil), context.Canceled)
+	assert.ErrorIs(t, sender.FlushContext(ctx), context.Canceled)
+	assert.Len(t, pointHandler.Lines, 1)
+	assert.NoError(t, sender.CloseContext(ctx))
+}
+
+var errBufferFull = errors.New("buffer full")
+
+// slowSender blocks its flushes and closes until release is closed.
+type slowSender struct {
+	Sender
+	release chan struct{}
+}
+
+func (s *slowSender) FlushContext(ctx context.Context) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *slowSender) Close() {
+	<-s.release
+}
+
+func TestMultiSender_DestinationErrors(t *testing.T) {
+	failing := newMockSender()
+	failing.destination = "http://localhost:2878"
+	failing.pointHandler.(*mockHandler).Error = errBufferFull
+	named := newMockSender()
+	named.pointHandler.(*mockHandler).Error = errBufferFull
+	ignored := newMockSender()
+	ignored.pointHandler.(*mockHandler).Error = errBufferFull
+	noop, _ := NewWavefrontNoOpClient()
+	sender := NewMultiSender(failing, Named("backup", named), BestEffort(Named("archive", ignored)), noop)
+
+	err := sender.SendMetric("foo", 20, 0, "test", nil)
+	assert.EqualError(t, err, "2 errors: http://localhost:2878: buffer full,backup: buffer full")
+	assert.ErrorIs(t, err, errBufferFull)
+	var destinationErr *DestinationError
+	require.ErrorAs(t, err, &destinationErr)
+	assert.Equal(t, "http://localhost:2878", destinationErr.Destination)
+	assert.Len(t, linesOf(ignored.pointHandler), 1)
+
+	err = NewMultiSender(newMockSender(), Named("failing", failing)).SendMetric("foo", 20, 0, "test", nil)
+	assert.EqualError(t, err, "failing: buffer full")
+}
+
+func TestMultiSender_ConcurrentFlush(t *testing.T) {
+	slow := &slowSender{release: make(chan struct{})}
+	fast := newMockSender()
+	fast.pointHandler.(*mockHandler).Error = errBufferFull
+	sender := NewMultiSender(slow, BestEffort(slow), fast)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := sender.FlushContext(ctx)
+	// both slow senders waited for the deadline at the same time.
+	assert.Less(t, time.Since(start), 190*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, errBufferFull)
+	assert.EqualError(t, err, "2 errors: sender 0: context deadline exceeded,sender 2: buffer full")
+
+	closed := make(chan struct{})
+	go func() {
+		sender.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("closed before the slow sender")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(slow.release)
+	<-closed
+}
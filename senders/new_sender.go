@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/wavefronthq/wavefront-sdk-go/internal"
+	"github.com/wavefronthq/wavefront-sdk-go/internal/auth"
 	"github.com/wavefronthq/wavefront-sdk-go/internal/sdkmetrics"
 )
 
@@ -14,14 +15,10 @@ func NewSender(wfURL string, setters ...Option) (Sender, error) {
 		return nil, fmt.Errorf("unable to create sender config: %s", err)
 	}
 
-	tokenService := tokenServiceForCfg(cfg)
-	client := cfg.HTTPClient
-	metricsReporter := internal.NewReporter(cfg.metricsURL(), tokenService, client)
-	tracesReporter := internal.NewReporter(cfg.tracesURL(), tokenService, client)
-
 	sender := &realSender{
 		defaultSource: internal.GetHostname("wavefront_direct_sender"),
 		proxy:         !cfg.Direct(),
+		destination:   cfg.mainEndpoint().metrics,
 	}
 	if cfg.SendInternalMetrics {
 		sender.internalRegistry = sender.realInternalRegistry(cfg)
@@ -29,19 +26,84 @@ func NewSender(wfURL string, setters ...Option) (Sender, error) {
 		sender.internalRegistry = sdkmetrics.NewNoOpRegistry()
 	}
 
+	endpoints := append([]endpoint{cfg.mainEndpoint()}, cfg.endpoints...)
+	var metricsEndpoints, tracesEndpoints []string
+	var metricsReporters, tracesReporters []internal.Reporter
+	var tokenService auth.Service
+	if cfg.Transport == transportHTTP {
+		tokenService = tokenServiceForCfg(cfg)
+	}
+	for _, e := range endpoints {
+		metricsEndpoints = append(metricsEndpoints, e.metrics)
+		tracesEndpoints = append(tracesEndpoints, e.traces)
+		switch cfg.Transport {
+		case transportTCP, transportUnix, transportUDP:
+			metricsConnection, tracesConnection := newConnections(cfg, e)
+			sender.connections = append(sender.connections, metricsConnection, tracesConnection)
+			metricsReporters = append(metricsReporters, metricsConnection.(internal.Reporter))
+			tracesReporters = append(tracesReporters, tracesConnection.(internal.Reporter))
+		default:
+			codec := internal.SetCodec(cfg.Codec)
+			metricsReporters = append(metricsReporters, internal.NewReporter(e.metrics, tokenService, cfg.HTTPClient, codec))
+			tracesReporters = append(tracesReporters, internal.NewReporter(e.traces, tokenService, cfg.HTTPClient, codec))
+		}
+	}
+
+	metricsReporter, tracesReporter := metricsReporters[0], tracesReporters[0]
+	switch {
+	case len(endpoints) > 1:
+		metricsReporter = newEndpointPool("metrics", metricsEndpoints, metricsReporters, cfg, sender.internalRegistry)
+		tracesReporter = newEndpointPool("traces", tracesEndpoints, tracesReporters, cfg, sender.internalRegistry)
+	case cfg.CircuitBreakerThreshold > 0:
+		metricsReporter = newCircuitBreaker("metrics", metricsEndpoints[0], metricsReporter, cfg, sender.internalRegistry)
+		tracesReporter = newCircuitBreaker("traces", tracesEndpoints[0], tracesReporter, cfg, sender.internalRegistry)
+	}
+
 	hf := internal.NewHandlerFactory(
 		metricsReporter,
 		tracesReporter,
 		cfg.FlushInterval,
 		cfg.MaxBufferSize,
 		sender.internalRegistry,
+		cfg.lineHandlerOptions()...,
 	)
 
-	sender.pointHandler = hf.NewPointHandler(cfg.BatchSize)
-	sender.histoHandler = hf.NewHistogramHandler(cfg.BatchSize)
-	sender.spanHandler = hf.NewSpanHandler(cfg.BatchSize)
-	sender.spanLogHandler = hf.NewSpanLogHandler(cfg.BatchSize)
-	sender.eventHandler = hf.NewEventHandler()
+	sender.pointHandler = hf.NewPointHandler(cfg.batchSize(PointData), cfg.dataTypeOptions(PointData)...)
+	sender.histoHandler = hf.NewHistogramHandler(cfg.batchSize(HistogramData), cfg.dataTypeOptions(HistogramData)...)
+	sender.spanHandler = hf.NewSpanHandler(cfg.batchSize(SpanData), cfg.dataTypeOptions(SpanData)...)
+	sender.spanLogHandler = hf.NewSpanLogHandler(cfg.batchSize(SpanLogData), cfg.dataTypeOptions(SpanLogData)...)
+	sender.eventHandler = hf.NewEventHandler(cfg.dataTypeOptions(EventData)...)
 	sender.Start()
 	return sender, nil
 }
+
+// newConnections creates the connections to the metrics and traces listeners of the proxy at e
+// for the plaintext transports.
+func newConnections(cfg *configuration, e endpoint) (internal.ConnectionHandler, internal.ConnectionHandler) {
+	timeout := cfg.httpClientConfiguration.Timeout
+	switch cfg.Transport {
+	case transportUnix:
+		return internal.NewUnixConnectionHandler(e.metrics, timeout),
+			internal.NewUnixConnectionHandler(e.traces, timeout)
+	case transportUDP:
+		return internal.NewUDPConnectionHandler(e.metrics, cfg.MaxDatagramSize, timeout),
+			internal.NewUDPConnectionHandler(e.traces, cfg.MaxDatagramSize, timeout)
+	default:
+		return internal.NewTCPConnectionHandler(e.metrics, timeout),
+			internal.NewTCPConnectionHandler(e.traces, timeout)
+	}
+}
+
+func newCircuitBreaker(
+	name string,
+	endpoint string,
+	reporter internal.Reporter,
+	cfg *configuration,
+	registry sdkmetrics.Registry,
+) internal.Reporter {
+	breaker := internal.NewCircuitBreaker(endpoint, reporter, cfg.CircuitBreakerThreshold, cfg.CircuitBreakerOpenTimeout)
+	registry.NewGauge("circuit_breaker."+name+".state", func() int64 {
+		return int64(breaker.State())
+	})
+	return breaker
+}
@@ -4,11 +4,13 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"net/http"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
 	"github.com/wavefronthq/wavefront-sdk-go/internal/auth"
 )
 
@@ -23,6 +25,13 @@ func TestScheme(t *testing.T) {
 	_, err = createConfig("https://localhost")
 	require.NoError(t, err)
 
+	_, err = createConfig("tcp://localhost")
+	require.NoError(t, err)
+	_, err = createConfig("unix:///var/run/wavefront.sock")
+	require.NoError(t, err)
+	_, err = createConfig("udp://localhost")
+	require.NoError(t, err)
+
 	_, err = createConfig("gopher://localhost")
 	require.Error(t, err)
 }
@@ -223,3 +232,238 @@ func TestSDKMetricsTags_Immutability(t *testing.T) {
 	_, ok := cfg2.SDKMetricsTags["baz"]
 	assert.False(t, ok)
 }
+
+func TestPersistentBuffer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "buffer")
+	cfg, err := createConfig("https://localhost", PersistentBuffer(dir, 1<<20))
+	require.NoError(t, err)
+	assert.Equal(t, dir, cfg.PersistentBufferDir)
+	assert.Equal(t, int64(1<<20), cfg.PersistentBufferMaxBytes)
+	assert.NoDirExists(t, dir, "the directory is created when the sender starts")
+
+	_, err = createConfig("https://localhost", PersistentBuffer(dir, 0))
+	assert.Error(t, err)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	cfg, err := createConfig("https://localhost", RetryPolicy(time.Second, time.Minute, 0.2, 5))
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, cfg.RetryPolicy.InitialBackoff)
+	assert.Equal(t, time.Minute, cfg.RetryPolicy.MaxBackoff)
+	assert.Equal(t, 0.2, cfg.RetryPolicy.Jitter)
+	assert.Equal(t, 5, cfg.RetryPolicy.MaxAttempts)
+
+	_, err = createConfig("https://localhost", RetryPolicy(time.Minute, time.Second, 0.2, 5))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", RetryPolicy(time.Second, time.Minute, 2, 5))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", RetryPolicy(time.Second, time.Minute, 0.2, -1))
+	assert.Error(t, err)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cfg, err := createConfig("https://localhost", CircuitBreaker(5, 30*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 5, cfg.CircuitBreakerThreshold)
+	assert.Equal(t, 30*time.Second, cfg.CircuitBreakerOpenTimeout)
+
+	_, err = createConfig("https://localhost", CircuitBreaker(5, 0))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", CircuitBreaker(-1, time.Second))
+	assert.Error(t, err)
+}
+
+func TestTCPScheme(t *testing.T) {
+	cfg, err := createConfig("tcp://localhost")
+	require.NoError(t, err)
+	assert.Equal(t, transportTCP, cfg.Transport)
+	assert.Equal(t, "localhost:2878", cfg.metricsAddress())
+	assert.Equal(t, "localhost:30001", cfg.tracesAddress())
+
+	cfg, err = createConfig("tcp://localhost:1234", TracesPort(4321))
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:1234", cfg.metricsAddress())
+	assert.Equal(t, "localhost:4321", cfg.tracesAddress())
+
+	_, err = createConfig("tcp://my-api-token@localhost")
+	assert.Error(t, err)
+}
+
+func TestUnixScheme(t *testing.T) {
+	cfg, err := createConfig("unix:///var/run/wavefront.sock")
+	require.NoError(t, err)
+	assert.Equal(t, transportUnix, cfg.Transport)
+	assert.Equal(t, "/var/run/wavefront.sock", cfg.MetricsSocketPath)
+	assert.Equal(t, "/var/run/wavefront.sock", cfg.TracesSocketPath)
+	assert.Empty(t, cfg.Path)
+
+	cfg, err = createConfig("unix:///var/run/metrics.sock", TracesSocket("/var/run/traces.sock"))
+	require.NoError(t, err)
+	assert.Equal(t, "/var/run/metrics.sock", cfg.MetricsSocketPath)
+	assert.Equal(t, "/var/run/traces.sock", cfg.TracesSocketPath)
+
+	_, err = createConfig("unix://")
+	assert.Error(t, err)
+	_, err = createConfig("unix:///var/run/wavefront.sock", APIToken("my-api-token"))
+	assert.Error(t, err)
+}
+
+func TestUDPScheme(t *testing.T) {
+	cfg, err := createConfig("udp://localhost:1234")
+	require.NoError(t, err)
+	assert.Equal(t, transportUDP, cfg.Transport)
+	assert.Equal(t, "localhost:1234", cfg.metricsAddress())
+	assert.Equal(t, "localhost:30001", cfg.tracesAddress())
+	assert.Equal(t, 1400, cfg.MaxDatagramSize)
+
+	cfg, err = createConfig("udp://localhost", MaxDatagramSize(8192))
+	require.NoError(t, err)
+	assert.Equal(t, 8192, cfg.MaxDatagramSize)
+
+	_, err = createConfig("udp://localhost", MaxDatagramSize(0))
+	assert.Error(t, err)
+	_, err = createConfig("udp://localhost", MaxDatagramSize(70_000))
+	assert.Error(t, err)
+	_, err = createConfig("udp://my-api-token@localhost")
+	assert.Error(t, err)
+}
+
+func TestOverflow(t *testing.T) {
+	cfg, err := createConfig("https://localhost", Overflow(Block, time.Second, PointData, EventData))
+	require.NoError(t, err)
+	assert.Equal(t, Block, *cfg.DataTypes[PointData].OverflowPolicy)
+	assert.Equal(t, time.Second, cfg.DataTypes[EventData].BlockTimeout)
+	assert.Nil(t, cfg.DataTypes[SpanData])
+	assert.Empty(t, cfg.dataTypeOptions(SpanData))
+	assert.Len(t, cfg.dataTypeOptions(PointData), 1)
+
+	cfg, err = createConfig("https://localhost", Overflow(DropOldest, 0))
+	require.NoError(t, err)
+	for _, dataType := range AllDataTypes {
+		assert.Equal(t, DropOldest, *cfg.DataTypes[dataType].OverflowPolicy)
+	}
+
+	cfg, err = createConfig("https://localhost", PersistentBuffer(t.TempDir(), 1<<20))
+	require.NoError(t, err)
+	assert.Len(t, cfg.dataTypeOptions(SpanData), 1, "spills to disk by default")
+
+	_, err = createConfig("https://localhost", Overflow(SpillToDisk, 0))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", Overflow(Block, -time.Second))
+	assert.Error(t, err)
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg, err := createConfig("https://localhost", RateLimit(100, 0, DropExcess, PointData))
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, cfg.DataTypes[PointData].RateLimit)
+	assert.Equal(t, DropExcess, cfg.DataTypes[PointData].RateLimitMode)
+	assert.Len(t, cfg.dataTypeOptions(PointData), 1)
+	assert.Empty(t, cfg.dataTypeOptions(SpanData))
+
+	cfg, err = createConfig("https://localhost", RateLimit(100, 200, QueueExcess))
+	require.NoError(t, err)
+	for _, dataType := range AllDataTypes {
+		assert.Equal(t, 200, cfg.DataTypes[dataType].RateLimitBurst)
+	}
+
+	_, err = createConfig("https://localhost", RateLimit(-1, 0, QueueExcess))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", RateLimit(1, -1, QueueExcess))
+	assert.Error(t, err)
+}
+
+func TestMaxBatchBytes(t *testing.T) {
+	cfg, err := createConfig("https://localhost", MaxBatchBytes(1<<20))
+	require.NoError(t, err)
+	assert.Equal(t, 1<<20, cfg.MaxBatchBytes)
+	assert.Len(t, cfg.lineHandlerOptions(), 1)
+
+	_, err = createConfig("https://localhost", MaxBatchBytes(-1))
+	assert.Error(t, err)
+}
+
+func TestDataTypeSettings(t *testing.T) {
+	cfg, err := createConfig("https://localhost",
+		BatchSizeFor(500, SpanData, SpanLogData),
+		FlushIntervalFor(5*time.Second, HistogramData),
+		MaxBufferSizeFor(1000, SpanData))
+	require.NoError(t, err)
+	assert.Equal(t, 500, cfg.batchSize(SpanData))
+	assert.Equal(t, defaultBatchSize, cfg.batchSize(PointData))
+	assert.Equal(t, 1, cfg.batchSize(EventData))
+	assert.Equal(t, 1000, cfg.maxBufferSize(SpanData))
+	assert.Equal(t, defaultBufferSize, cfg.maxBufferSize(SpanLogData))
+
+	sender, err := NewSender("http://localhost",
+		BatchSizeFor(500, SpanData),
+		FlushIntervalFor(5*time.Second, HistogramData),
+		MaxBufferSizeFor(1000, SpanData),
+		SendInternalMetrics(false))
+	require.NoError(t, err)
+	defer sender.Close()
+	spanHandler := sender.(*realSender).spanHandler.(*internal.RealLineHandler)
+	assert.Equal(t, 500, spanHandler.BatchSize)
+	assert.Equal(t, 1000, spanHandler.MaxBufferSize)
+	pointHandler := sender.(*realSender).pointHandler.(*internal.RealLineHandler)
+	assert.Equal(t, defaultBatchSize, pointHandler.BatchSize)
+	assert.Equal(t, defaultBufferSize, pointHandler.MaxBufferSize)
+
+	_, err = createConfig("https://localhost", BatchSizeFor(10, EventData))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", BatchSizeFor(2000, SpanData), MaxBufferSizeFor(1000, SpanData))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", MaxBufferSizeFor(100, PointData))
+	assert.Error(t, err, "the default batch size does not fit in the buffer")
+	_, err = createConfig("https://localhost", FlushIntervalFor(-time.Second, SpanData))
+	assert.Error(t, err)
+}
+
+func TestFlushWorkers(t *testing.T) {
+	cfg, err := createConfig("https://localhost", FlushWorkers(2), FlushWorkers(8, PointData))
+	require.NoError(t, err)
+	assert.Equal(t, 8, cfg.dataType(PointData).FlushWorkers)
+	for _, dataType := range []DataType{HistogramData, SpanData, SpanLogData, EventData} {
+		assert.Equal(t, 2, cfg.dataType(dataType).FlushWorkers, dataType)
+	}
+
+	_, err = createConfig("https://localhost", FlushWorkers(-1, SpanData))
+	assert.Error(t, err)
+}
+
+func TestAdaptiveFlush(t *testing.T) {
+	cfg, err := createConfig("https://localhost", AdaptiveFlush(100, time.Second, PointData))
+	require.NoError(t, err)
+	assert.True(t, cfg.dataType(PointData).AdaptiveFlush)
+	assert.Equal(t, 100, cfg.dataType(PointData).LowWaterMark)
+	assert.Equal(t, time.Second, cfg.dataType(PointData).FlushBudget)
+	assert.False(t, cfg.dataType(SpanData).AdaptiveFlush)
+
+	_, err = createConfig("https://localhost", AdaptiveFlush(-1, 0))
+	assert.Error(t, err)
+}
+
+func TestEndpoints(t *testing.T) {
+	cfg, err := createConfig("https://localhost:8443/prefix", Endpoints(Failover, "https://backup", "http://other:8080/path"))
+	require.NoError(t, err)
+	assert.Equal(t, Failover, cfg.EndpointPolicy)
+	assert.Equal(t, []endpoint{
+		{metrics: "https://backup:8443/prefix", traces: "https://backup:8443/prefix"},
+		{metrics: "http://other:8080/path", traces: "http://other:8080/path"},
+	}, cfg.endpoints)
+
+	cfg, err = createConfig("tcp://localhost", Endpoints(RoundRobin, "tcp://backup:1234"))
+	require.NoError(t, err)
+	assert.Equal(t, []endpoint{{metrics: "backup:1234", traces: "backup:30001"}}, cfg.endpoints)
+
+	cfg, err = createConfig("unix:///var/run/wavefront.sock", Endpoints(LeastFailures, "unix:///var/run/backup.sock"))
+	require.NoError(t, err)
+	assert.Equal(t, []endpoint{{metrics: "/var/run/backup.sock", traces: "/var/run/backup.sock"}}, cfg.endpoints)
+
+	_, err = createConfig("https://localhost", Endpoints(RoundRobin, "tcp://backup"))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", Endpoints(RoundRobin, "https://my-api-token@backup"))
+	assert.Error(t, err)
+	_, err = createConfig("https://localhost", Endpoints(LoadBalancingPolicy(7), "https://backup"))
+	assert.Error(t, err)
+}
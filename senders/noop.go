@@ -1,6 +1,8 @@
 package senders
 
 import (
+	"context"
+
 	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 )
@@ -44,14 +46,62 @@ func (sender *noOpSender) SendEvent(string, int64, int64, string, map[string]str
 	return nil
 }
 
+func (sender *noOpSender) SendMetricContext(context.Context, string, float64, int64, string, map[string]string) error {
+	return nil
+}
+
+func (sender *noOpSender) SendDeltaCounterContext(context.Context, string, float64, string, map[string]string) error {
+	return nil
+}
+
+func (sender *noOpSender) SendDistributionContext(context.Context, string, []histogram.Centroid, map[histogram.Granularity]bool, int64, string, map[string]string) error {
+	return nil
+}
+
+func (sender *noOpSender) SendSpanContext(context.Context, string, int64, int64, string, string, string, []string, []string, []SpanTag, []SpanLog) error {
+	return nil
+}
+
+func (sender *noOpSender) SendEventContext(context.Context, string, int64, int64, string, map[string]string, ...event.Option) error {
+	return nil
+}
+
 func (sender *noOpSender) Close() {
 	// no-op
 }
 
+func (sender *noOpSender) CloseContext(context.Context) error {
+	return nil
+}
+
+func (sender *noOpSender) Shutdown(context.Context) (ShutdownResult, error) {
+	return ShutdownResult{}, nil
+}
+
 func (sender *noOpSender) Flush() error {
 	return nil
 }
 
+func (sender *noOpSender) FlushContext(context.Context) error {
+	return nil
+}
+
 func (sender *noOpSender) GetFailureCount() int64 {
 	return 0
 }
+
+func (sender *noOpSender) SendPoints(context.Context, []Point) error {
+	return nil
+}
+
+func (sender *noOpSender) SendDistributions(context.Context, []Distribution) error {
+	return nil
+}
+
+func (sender *noOpSender) SendSpans(context.Context, []Span) error {
+	return nil
+}
+
+func (sender *noOpSender) SendEvents(context.Context, []Event) error {
+	return nil
+}
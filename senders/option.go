@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
 	"github.com/wavefronthq/wavefront-sdk-go/internal/auth"
 )
 
@@ -87,6 +88,79 @@ func BatchSize(n int) Option {
 	}
 }
 
+// BatchSizeFor sets the max number of items of the given types sent per flush interval,
+// overriding BatchSize. Events are always sent one at a time.
+func BatchSizeFor(n int, dataTypes ...DataType) Option {
+	return func(cfg *configuration) {
+		for _, dataType := range dataTypes {
+			cfg.dataType(dataType).BatchSize = n
+		}
+	}
+}
+
+// FlushIntervalFor sets how often data of the given types is flushed, overriding FlushInterval.
+func FlushIntervalFor(interval time.Duration, dataTypes ...DataType) Option {
+	return func(cfg *configuration) {
+		for _, dataType := range dataTypes {
+			cfg.dataType(dataType).FlushInterval = interval
+		}
+	}
+}
+
+// MaxBufferSizeFor sets the number of items of the given types buffered in memory,
+// overriding MaxBufferSize. It must not be lower than the batch size of the types.
+func MaxBufferSizeFor(n int, dataTypes ...DataType) Option {
+	return func(cfg *configuration) {
+		for _, dataType := range dataTypes {
+			cfg.dataType(dataType).MaxBufferSize = n
+		}
+	}
+}
+
+// FlushWorkers sets the number of batches of the given types, or of all types if none is given,
+// reported concurrently on every flush interval. It raises the max throughput of a type from
+// BatchSize to n * BatchSize items per flush interval, at the cost of as many concurrent requests.
+// A batch that fails to be reported is buffered again, or retried according to the RetryPolicy,
+// as with a single worker. Defaults to 1.
+func FlushWorkers(n int, dataTypes ...DataType) Option {
+	if len(dataTypes) == 0 {
+		dataTypes = AllDataTypes
+	}
+	return func(cfg *configuration) {
+		for _, dataType := range dataTypes {
+			cfg.dataType(dataType).FlushWorkers = n
+		}
+	}
+}
+
+// AdaptiveFlush makes every flush of the given types, or of all types if none is given, keep
+// sending batches until no more than lowWaterMark items are buffered, instead of sending a single
+// batch. A flush stops early when a batch fails to be sent or once it has been running for budget,
+// 0 meaning the flush interval of the type. The number of batches sent by the last flush of each
+// type is reported by the .flush.batches_per_tick internal metric.
+func AdaptiveFlush(lowWaterMark int, budget time.Duration, dataTypes ...DataType) Option {
+	if len(dataTypes) == 0 {
+		dataTypes = AllDataTypes
+	}
+	return func(cfg *configuration) {
+		for _, dataType := range dataTypes {
+			typeCfg := cfg.dataType(dataType)
+			typeCfg.AdaptiveFlush = true
+			typeCfg.LowWaterMark = lowWaterMark
+			typeCfg.FlushBudget = budget
+		}
+	}
+}
+
+// MaxBatchBytes caps the size in bytes of the uncompressed body of each request, on top of
+// BatchSize, splitting batches as needed. A single line larger than n is sent on its own.
+// Disabled by default.
+func MaxBatchBytes(n int) Option {
+	return func(cfg *configuration) {
+		cfg.MaxBatchBytes = n
+	}
+}
+
 // MaxBufferSize set the size of internal buffers beyond which received data is dropped. Defaults to 50,000.
 func MaxBufferSize(n int) Option {
 	return func(cfg *configuration) {
@@ -108,6 +182,122 @@ func FlushInterval(interval time.Duration) Option {
 	}
 }
 
+// PersistentBuffer enables a disk-backed buffer stored in dir. Lines are spilled to segmented files,
+// and replayed the next time a sender using the same dir is started, when they do not fit in the
+// internal buffers, unless another Overflow policy is set, when a RetryPolicy gives up on their batch,
+// and when they are still buffered in memory as the sender is closed. Without a RetryPolicy, the lines
+// of a batch that failed to be reported are buffered in memory again, and only spilled if the
+// internal buffer is full.
+// maxBytes caps the disk usage of each data type. dir is created when the sender starts. Disabled by default.
+// The same dir must not be shared by senders that are running at the same time.
+func PersistentBuffer(dir string, maxBytes int64) Option {
+	return func(cfg *configuration) {
+		cfg.PersistentBufferDir = dir
+		cfg.PersistentBufferMaxBytes = maxBytes
+	}
+}
+
+// Overflow sets what happens to data of the given types, or of all types if none is given,
+// sent while their buffer is full.
+// With the Block policy, blockTimeout bounds how long a call is blocked, 0 meaning until
+// there is room in the buffer. The SpillToDisk policy requires a PersistentBuffer.
+// Each outcome is counted by the internal metrics of the data type: .dropped, .evicted,
+// .blocked and .spilled.
+func Overflow(policy OverflowPolicy, blockTimeout time.Duration, dataTypes ...DataType) Option {
+	if len(dataTypes) == 0 {
+		dataTypes = AllDataTypes
+	}
+	return func(cfg *configuration) {
+		for _, dataType := range dataTypes {
+			typeCfg := cfg.dataType(dataType)
+			typeCfg.OverflowPolicy = &policy
+			typeCfg.BlockTimeout = blockTimeout
+		}
+	}
+}
+
+// RateLimit caps the rate at which data of the given types, or of all types if none is given,
+// is reported to linesPerSecond, with bursts of up to burst lines; a burst of 0 allows one
+// second worth of lines. Each type has its own limit. With QueueExcess, data sent faster than
+// the limit stays buffered, subject to the Overflow policy; with DropExcess, it is dropped.
+// The lines held back at a flush, or dropped, are counted by the .rate_limited internal metric
+// of their data type.
+func RateLimit(linesPerSecond float64, burst int, mode RateLimitMode, dataTypes ...DataType) Option {
+	if len(dataTypes) == 0 {
+		dataTypes = AllDataTypes
+	}
+	return func(cfg *configuration) {
+		for _, dataType := range dataTypes {
+			typeCfg := cfg.dataType(dataType)
+			typeCfg.RateLimit = linesPerSecond
+			typeCfg.RateLimitBurst = burst
+			typeCfg.RateLimitMode = mode
+		}
+	}
+}
+
+// RetryPolicy makes the sender retry a batch that failed to be reported with exponential backoff,
+// instead of buffering its lines again and retrying them on every flush.
+// The first retry happens after initialBackoff, and the delay doubles on every retry up to maxBackoff.
+// jitter, between 0 and 1, is the fraction of each delay that is randomized.
+// A batch is dropped after maxAttempts attempts, 0 meaning no limit.
+// A Retry-After header sent along a 429 or 503 response is honored whether or not a RetryPolicy is set.
+func RetryPolicy(initialBackoff, maxBackoff time.Duration, jitter float64, maxAttempts int) Option {
+	return func(cfg *configuration) {
+		cfg.RetryPolicy = &internal.RetryPolicy{
+			InitialBackoff: initialBackoff,
+			MaxBackoff:     maxBackoff,
+			Jitter:         jitter,
+			MaxAttempts:    maxAttempts,
+		}
+	}
+}
+
+// Compression sets the level at which the body of HTTP requests is compressed with gzip.
+// NoCompression sends requests uncompressed, which saves CPU when sending to a proxy on
+// the same host. Defaults to DefaultCompression.
+func Compression(level CompressionLevel) Option {
+	return func(cfg *configuration) {
+		cfg.CompressionLevel = level
+	}
+}
+
+// CompressionCodec sets how the body of HTTP requests is compressed, for instance with
+// DeflateCodec, or with any Codec implementing another Content-Encoding. It overrides Compression.
+func CompressionCodec(codec Codec) Option {
+	return func(cfg *configuration) {
+		cfg.Codec = codec
+	}
+}
+
+// CircuitBreaker suspends reporting to an endpoint for openTimeout after failureThreshold
+// consecutive failed reports, then lets a single report through to probe whether it recovered.
+// Data keeps being buffered while reporting is suspended.
+// The metrics and traces endpoints have their own breakers, whose state is reported as the
+// circuit_breaker.metrics.state and circuit_breaker.traces.state internal metrics:
+// 0 for closed, 1 for open and 2 for half-open.
+func CircuitBreaker(failureThreshold int, openTimeout time.Duration) Option {
+	return func(cfg *configuration) {
+		cfg.CircuitBreakerThreshold = failureThreshold
+		cfg.CircuitBreakerOpenTimeout = openTimeout
+	}
+}
+
+// Endpoints sets additional proxies to send data to, on top of the one of the Sender URL,
+// and how data is spread over them. Each URL must use the scheme of the Sender URL, and
+// its ports and path default to the ones of the Sender.
+// The health of each endpoint is tracked by its own circuit breaker, configured with the
+// CircuitBreaker Option, or opening after 3 consecutive failures for 30 seconds by default.
+// Data failing to be sent to an endpoint is sent to the next healthy one.
+// The state of each breaker is reported as the endpoints.metrics.<index>.state and
+// endpoints.traces.<index>.state internal metrics, index 0 being the Sender URL.
+func Endpoints(policy LoadBalancingPolicy, urls ...string) Option {
+	return func(cfg *configuration) {
+		cfg.EndpointPolicy = policy
+		cfg.Endpoints = append(cfg.Endpoints, urls...)
+	}
+}
+
 // MetricsPort sets the port on which to report metrics. Default is 2878.
 func MetricsPort(port int) Option {
 	return func(cfg *configuration) {
@@ -122,7 +312,25 @@ func TracesPort(port int) Option {
 	}
 }
 
+// TracesSocket sets the path of the Unix domain socket on which to report traces,
+// with the unix transport. Defaults to the socket given in the sender URL.
+func TracesSocket(path string) Option {
+	return func(cfg *configuration) {
+		cfg.TracesSocketPath = path
+	}
+}
+
+// MaxDatagramSize sets the max size in bytes of the datagrams sent with the udp transport.
+// Lines are never split across datagrams, and lines larger than this size are dropped.
+// Defaults to 1,400 bytes, which avoids IP fragmentation on most networks.
+func MaxDatagramSize(size int) Option {
+	return func(cfg *configuration) {
+		cfg.MaxDatagramSize = size
+	}
+}
+
 // Timeout sets the HTTP timeout. Defaults to 10 seconds.
+// With the tcp, unix and udp transports, it bounds connecting and each write instead.
 func Timeout(timeout time.Duration) Option {
 	return func(cfg *configuration) {
 		if cfg.HTTPClient != nil {
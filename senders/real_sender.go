@@ -1,9 +1,13 @@
 package senders
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"sync"
 
 	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
@@ -22,6 +26,8 @@ type Sender interface {
 	DistributionSender
 	SpanSender
 	EventSender
+	ContextSender
+	BatchSender
 	internal.Flusher
 	Close()
 	private()
@@ -36,9 +42,15 @@ type realSender struct {
 	eventHandler     internal.LineHandler
 	internalRegistry sdkmetrics.Registry
 	proxy            bool
+	connections      []internal.ConnectionHandler
+	// where data is sent to, naming the sender in the errors of a MultiSender.
+	destination string
 }
 
 func (sender *realSender) Start() {
+	for _, connection := range sender.connections {
+		connection.Start()
+	}
 	sender.pointHandler.Start()
 	sender.histoHandler.Start()
 	sender.spanHandler.Start()
@@ -51,8 +63,13 @@ func (sender *realSender) private() {
 }
 
 func (sender *realSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
+	return sender.SendMetricContext(context.Background(), name, value, ts, source, tags)
+}
+
+func (sender *realSender) SendMetricContext(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error {
 	line, err := metric.Line(name, value, ts, source, tags, sender.defaultSource)
 	return trySendWith(
+		ctx,
 		line,
 		err,
 		sender.pointHandler,
@@ -61,6 +78,10 @@ func (sender *realSender) SendMetric(name string, value float64, ts int64, sourc
 }
 
 func (sender *realSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	return sender.SendDeltaCounterContext(context.Background(), name, value, source, tags)
+}
+
+func (sender *realSender) SendDeltaCounterContext(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
 	if name == "" {
 		sender.internalRegistry.PointsTracker().IncInvalid()
 		return fmt.Errorf("empty metric name")
@@ -69,7 +90,7 @@ func (sender *realSender) SendDeltaCounter(name string, value float64, source st
 		name = internal.DeltaCounterName(name)
 	}
 	if value > 0 {
-		return sender.SendMetric(name, value, 0, source, tags)
+		return sender.SendMetricContext(ctx, name, value, 0, source, tags)
 	}
 	return nil
 }
@@ -81,9 +102,22 @@ func (sender *realSender) SendDistribution(
 	ts int64,
 	source string,
 	tags map[string]string,
+) error {
+	return sender.SendDistributionContext(context.Background(), name, centroids, hgs, ts, source, tags)
+}
+
+func (sender *realSender) SendDistributionContext(
+	ctx context.Context,
+	name string,
+	centroids []histogram.Centroid,
+	hgs map[histogram.Granularity]bool,
+	ts int64,
+	source string,
+	tags map[string]string,
 ) error {
 	line, err := histogramInternal.Line(name, centroids, hgs, ts, source, tags, sender.defaultSource)
 	return trySendWith(
+		ctx,
 		line,
 		err,
 		sender.histoHandler,
@@ -91,14 +125,14 @@ func (sender *realSender) SendDistribution(
 	)
 }
 
-func trySendWith(line string, err error, handler internal.LineHandler, tracker sdkmetrics.SuccessTracker) error {
+func trySendWith(ctx context.Context, line string, err error, handler internal.LineHandler, tracker sdkmetrics.SuccessTracker) error {
 	if err != nil {
 		tracker.IncInvalid()
 		return err
 	}
 
 	tracker.IncValid()
-	err = handler.HandleLine(line)
+	err = handler.HandleLineContext(ctx, line)
 	if err != nil {
 		tracker.IncDropped()
 	}
@@ -113,6 +147,19 @@ func (sender *realSender) SendSpan(
 	tags []SpanTag,
 	spanLogs []SpanLog,
 ) error {
+	return sender.SendSpanContext(context.Background(), name, startMillis, durationMillis, source,
+		traceID, spanID, parents, followsFrom, tags, spanLogs)
+}
+
+func (sender *realSender) SendSpanContext(
+	ctx context.Context,
+	name string,
+	startMillis, durationMillis int64,
+	source, traceID, spanID string,
+	parents, followsFrom []string,
+	tags []SpanTag,
+	spanLogs []SpanLog,
+) error {
 
 	logs := makeSpanLogs(spanLogs)
 	line, err := span.Line(
@@ -129,6 +176,7 @@ func (sender *realSender) SendSpan(
 		sender.defaultSource,
 	)
 	err = trySendWith(
+		ctx,
 		line,
 		err,
 		sender.spanHandler,
@@ -140,6 +188,7 @@ func (sender *realSender) SendSpan(
 	if len(spanLogs) > 0 {
 		logJSON, logJSONErr := span.LogJSON(traceID, spanID, logs, line)
 		return trySendWith(
+			ctx,
 			logJSON,
 			logJSONErr,
 			sender.spanLogHandler,
@@ -170,6 +219,17 @@ func (sender *realSender) SendEvent(
 	source string,
 	tags map[string]string,
 	setters ...event.Option,
+) error {
+	return sender.SendEventContext(context.Background(), name, startMillis, endMillis, source, tags, setters...)
+}
+
+func (sender *realSender) SendEventContext(
+	ctx context.Context,
+	name string,
+	startMillis, endMillis int64,
+	source string,
+	tags map[string]string,
+	setters ...event.Option,
 ) error {
 	var line string
 	var err error
@@ -180,6 +240,7 @@ func (sender *realSender) SendEvent(
 	}
 
 	return trySendWith(
+		ctx,
 		line,
 		err,
 		sender.eventHandler,
@@ -187,41 +248,200 @@ func (sender *realSender) SendEvent(
 	)
 }
 
-func (sender *realSender) Close() {
-	sender.pointHandler.Stop()
-	sender.histoHandler.Stop()
-	sender.spanHandler.Stop()
-	sender.spanLogHandler.Stop()
-	sender.internalRegistry.Stop()
-	sender.eventHandler.Stop()
+func (sender *realSender) SendPoints(ctx context.Context, points []Point) error {
+	lines := make([]string, len(points))
+	var invalid BatchError
+	for i, p := range points {
+		var err error
+		lines[i], err = metric.Line(p.Name, p.Value, p.Timestamp, p.Source, p.Tags, sender.defaultSource)
+		invalid.add(i, err)
+	}
+	return trySendLinesWith(ctx, lines, nil, &invalid, sender.pointHandler, sender.internalRegistry.PointsTracker())
 }
 
-func (sender *realSender) Flush() error {
-	errStr := ""
-	err := sender.pointHandler.Flush()
-	if err != nil {
-		errStr = errStr + err.Error() + "\n"
+func (sender *realSender) SendDistributions(ctx context.Context, distributions []Distribution) error {
+	lines := make([]string, len(distributions))
+	var invalid BatchError
+	for i, d := range distributions {
+		var err error
+		lines[i], err = histogramInternal.Line(d.Name, d.Centroids, d.Granularities, d.Timestamp, d.Source, d.Tags, sender.defaultSource)
+		invalid.add(i, err)
 	}
-	err = sender.histoHandler.Flush()
-	if err != nil {
-		errStr = errStr + err.Error() + "\n"
+	return trySendLinesWith(ctx, lines, nil, &invalid, sender.histoHandler, sender.internalRegistry.HistogramsTracker())
+}
+
+// SendSpans sends the spans and their span logs in one batch. The span logs of the spans
+// that could not be buffered are dropped.
+func (sender *realSender) SendSpans(ctx context.Context, spans []Span) error {
+	lines := make([]string, len(spans))
+	var logLines []string
+	var logIndices []int
+	var invalid BatchError
+	for i, s := range spans {
+		logs := makeSpanLogs(s.SpanLogs)
+		var err error
+		lines[i], err = span.Line(s.Name, s.StartMillis, s.DurationMillis, s.Source, s.TraceID, s.SpanID,
+			s.Parents, s.FollowsFrom, makeSpanTags(s.Tags), logs, sender.defaultSource)
+		if err != nil {
+			invalid.add(i, err)
+			continue
+		}
+		if len(s.SpanLogs) > 0 {
+			logJSON, err := span.LogJSON(s.TraceID, s.SpanID, logs, lines[i])
+			invalid.add(i, err)
+			logLines = append(logLines, logJSON)
+			logIndices = append(logIndices, i)
+		}
 	}
-	err = sender.spanHandler.Flush()
-	if err != nil {
-		errStr = errStr + err.Error()
+	err := trySendLinesWith(ctx, lines, nil, &invalid, sender.spanHandler, sender.internalRegistry.SpansTracker())
+	if len(invalid.Errors) > 0 {
+		return err
 	}
-	err = sender.spanLogHandler.Flush()
-	if err != nil {
-		errStr = errStr + err.Error()
+
+	var batchErr BatchError
+	var spansErr, logsErr *BatchError
+	if errors.As(err, &spansErr) {
+		// the span logs of the spans that were dropped are dropped as well.
+		batchErr.Errors = spansErr.Errors
+		logLines, logIndices = withoutFailedItems(logLines, logIndices, spansErr)
 	}
-	err = sender.eventHandler.Flush()
-	if err != nil {
-		errStr = errStr + err.Error()
+	err = trySendLinesWith(ctx, logLines, logIndices, &BatchError{}, sender.spanLogHandler, sender.internalRegistry.SpanLogsTracker())
+	if errors.As(err, &logsErr) {
+		batchErr.Errors = append(batchErr.Errors, logsErr.Errors...)
+	}
+	return batchErr.get()
+}
+
+// withoutFailedItems returns the lines of the items of a batch, at indices, whose item did not fail with batchErr.
+func withoutFailedItems(lines []string, indices []int, batchErr *BatchError) ([]string, []int) {
+	failed := make(map[int]bool, len(batchErr.Errors))
+	for _, itemErr := range batchErr.Errors {
+		failed[itemErr.Index] = true
 	}
-	if errStr != "" {
-		return fmt.Errorf(errStr)
+	var keptLines []string
+	var keptIndices []int
+	for i, index := range indices {
+		if !failed[index] {
+			keptLines = append(keptLines, lines[i])
+			keptIndices = append(keptIndices, index)
+		}
 	}
-	return nil
+	return keptLines, keptIndices
+}
+
+func (sender *realSender) SendEvents(ctx context.Context, events []Event) error {
+	lines := make([]string, len(events))
+	var invalid BatchError
+	for i, e := range events {
+		var err error
+		if sender.proxy {
+			lines[i], err = eventInternal.Line(e.Name, e.StartMillis, e.EndMillis, e.Source, e.Tags, e.Options...)
+		} else {
+			lines[i], err = eventInternal.LineJSON(e.Name, e.StartMillis, e.EndMillis, e.Source, e.Tags, e.Options...)
+		}
+		invalid.add(i, err)
+	}
+	return trySendLinesWith(ctx, lines, nil, &invalid, sender.eventHandler, sender.internalRegistry.EventsTracker())
+}
+
+// trySendLinesWith buffers lines, the lines of the items of a batch, unless some items are invalid.
+// indices are the items of the lines, when they are not the items of the batch in order.
+func trySendLinesWith(
+	ctx context.Context,
+	lines []string,
+	indices []int,
+	invalid *BatchError,
+	handler internal.LineHandler,
+	tracker sdkmetrics.SuccessTracker,
+) error {
+	if len(invalid.Errors) > 0 {
+		for range invalid.Errors {
+			tracker.IncInvalid()
+		}
+		return invalid.get()
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	for range lines {
+		tracker.IncValid()
+	}
+	var batchErr BatchError
+	for i, err := range handler.HandleLines(ctx, lines) {
+		if err == nil {
+			continue
+		}
+		tracker.IncDropped()
+		if indices != nil {
+			batchErr.add(indices[i], err)
+		} else {
+			batchErr.add(i, err)
+		}
+	}
+	return batchErr.get()
+}
+
+func (sender *realSender) Close() {
+	if err := sender.CloseContext(context.Background()); err != nil {
+		log.Println(err)
+	}
+}
+
+func (sender *realSender) CloseContext(ctx context.Context) error {
+	_, err := sender.Shutdown(ctx)
+	return err
+}
+
+func (sender *realSender) Shutdown(ctx context.Context) (ShutdownResult, error) {
+	sender.internalRegistry.Stop()
+
+	handlers := map[DataType]internal.LineHandler{
+		PointData:     sender.pointHandler,
+		HistogramData: sender.histoHandler,
+		SpanData:      sender.spanHandler,
+		SpanLogData:   sender.spanLogHandler,
+		EventData:     sender.eventHandler,
+	}
+	result := ShutdownResult{DataTypes: make(map[DataType]DrainStats, len(handlers))}
+	var errors multiError
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for dataType, handler := range handlers {
+		wg.Add(1)
+		go func(dataType DataType, handler internal.LineHandler) {
+			defer wg.Done()
+			drained := handler.Drain(ctx)
+			mtx.Lock()
+			defer mtx.Unlock()
+			result.DataTypes[dataType] = DrainStats{
+				Delivered: drained.Delivered,
+				Persisted: drained.Persisted,
+				Lost:      drained.Lost,
+			}
+			errors.add(drained.Err)
+		}(dataType, handler)
+	}
+	wg.Wait()
+
+	for _, connection := range sender.connections {
+		connection.Close()
+	}
+	return result, errors.get()
+}
+
+func (sender *realSender) Flush() error {
+	return sender.FlushContext(context.Background())
+}
+
+func (sender *realSender) FlushContext(ctx context.Context) error {
+	var errors multiError
+	errors.add(sender.pointHandler.FlushContext(ctx))
+	errors.add(sender.histoHandler.FlushContext(ctx))
+	errors.add(sender.spanHandler.FlushContext(ctx))
+	errors.add(sender.spanLogHandler.FlushContext(ctx))
+	errors.add(sender.eventHandler.FlushContext(ctx))
+	return errors.get()
 }
 
 func (sender *realSender) GetFailureCount() int64 {
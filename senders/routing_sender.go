@@ -0,0 +1,310 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// DefaultRoute is the name of the route of the data that matches none of the routes of a RoutingSender.
+const DefaultRoute = "default"
+
+// Datum describes the data a Route is matched against.
+type Datum struct {
+	// Type is PointData for metrics and delta counters, HistogramData for distributions,
+	// SpanData for spans along with their span logs, and EventData for events.
+	Type   DataType
+	Name   string
+	Source string
+
+	tags     map[string]string
+	spanTags []SpanTag
+}
+
+// Tag returns the value of the tag key of the datum, and whether it has it.
+// A span having several values for key returns the first one.
+func (d Datum) Tag(key string) (string, bool) {
+	if d.Type == SpanData {
+		for _, tag := range d.spanTags {
+			if tag.Key == key {
+				return tag.Value, true
+			}
+		}
+		return "", false
+	}
+	value, ok := d.tags[key]
+	return value, ok
+}
+
+// Matcher decides whether a datum is sent to the Sender of a Route.
+type Matcher func(d Datum) bool
+
+// NamePrefix matches the data whose name starts with prefix.
+func NamePrefix(prefix string) Matcher {
+	return func(d Datum) bool {
+		return strings.HasPrefix(d.Name, prefix)
+	}
+}
+
+// NameRegexp matches the data whose name matches expr.
+func NameRegexp(expr *regexp.Regexp) Matcher {
+	return func(d Datum) bool {
+		return expr.MatchString(d.Name)
+	}
+}
+
+// TagEquals matches the data whose tag key is value.
+func TagEquals(key, value string) Matcher {
+	return func(d Datum) bool {
+		v, ok := d.Tag(key)
+		return ok && v == value
+	}
+}
+
+// TagRegexp matches the data having a tag key whose value matches expr.
+func TagRegexp(key string, expr *regexp.Regexp) Matcher {
+	return func(d Datum) bool {
+		v, ok := d.Tag(key)
+		return ok && expr.MatchString(v)
+	}
+}
+
+// OfType matches the data of the given types.
+func OfType(dataTypes ...DataType) Matcher {
+	return func(d Datum) bool {
+		for _, dataType := range dataTypes {
+			if d.Type == dataType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Route sends the data matching every one of its Matchers to its Sender.
+// A Route without Matchers matches all data.
+type Route struct {
+	// keep this field as first element of struct
+	// to guarantee 64-bit alignment on 32-bit machines.
+	dropped int64
+
+	// Name identifies the route in the drop counts of the RoutingSender.
+	Name     string
+	Sender   Sender
+	Matchers []Matcher
+}
+
+func (r *Route) matches(d Datum) bool {
+	for _, match := range r.Matchers {
+		if !match(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// RoutingSender is a MultiSender sending each datum to a single Sender, chosen by its routes.
+type RoutingSender interface {
+	MultiSender
+	// Dropped returns the number of data dropped by each route, by route name, the data
+	// matching no route being counted under DefaultRoute. Data is dropped when the Sender
+	// of its route fails to send it, or when it matches no route and there is no default Sender.
+	Dropped() map[string]int64
+}
+
+type routingSender struct {
+	// flushes, closes and shuts down every Sender of the routes once.
+	*multiSender
+	routes       []*Route
+	defaultRoute *Route
+}
+
+// NewRoutingSender creates a RoutingSender sending each datum to the Sender of the first of
+// routes that matches it, and to defaultSender when none does. The data matching no route is
+// dropped when defaultSender is nil.
+// A Sender may be the Sender of several routes, and the default Sender.
+func NewRoutingSender(defaultSender Sender, routes ...Route) (RoutingSender, error) {
+	rs := &routingSender{
+		multiSender:  &multiSender{},
+		defaultRoute: &Route{Name: DefaultRoute, Sender: defaultSender},
+	}
+	names := map[string]bool{DefaultRoute: true}
+	for i := range routes {
+		route := routes[i]
+		if route.Name == "" || names[route.Name] {
+			return nil, fmt.Errorf("invalid route name: '%s', route names must be unique and not '%s'", route.Name, DefaultRoute)
+		}
+		if route.Sender == nil {
+			return nil, fmt.Errorf("missing sender for route '%s'", route.Name)
+		}
+		names[route.Name] = true
+		rs.routes = append(rs.routes, &route)
+		rs.addSender(route.Sender)
+	}
+	if defaultSender != nil {
+		rs.addSender(defaultSender)
+	}
+	return rs, nil
+}
+
+func (rs *routingSender) addSender(sender Sender) {
+	for _, s := range rs.senders {
+		if unwrapDestination(s) == unwrapDestination(sender) {
+			return
+		}
+	}
+	rs.senders = append(rs.senders, sender)
+}
+
+// route returns the route of d.
+func (rs *routingSender) route(d Datum) *Route {
+	for _, route := range rs.routes {
+		if route.matches(d) {
+			return route
+		}
+	}
+	return rs.defaultRoute
+}
+
+// send sends d with the Sender of its route, counting it as dropped if it could not be sent.
+// Errors are returned as DestinationErrors, unless the Sender is BestEffort.
+func (rs *routingSender) send(d Datum, send func(Sender) error) error {
+	route := rs.route(d)
+	if route.Sender == nil {
+		atomic.AddInt64(&route.dropped, 1)
+		return nil
+	}
+	err := send(route.Sender)
+	if err != nil {
+		atomic.AddInt64(&route.dropped, 1)
+	}
+	return destinationError(route.Sender, route.Name, err)
+}
+
+func (rs *routingSender) Dropped() map[string]int64 {
+	dropped := make(map[string]int64, len(rs.routes)+1)
+	for _, route := range rs.routes {
+		dropped[route.Name] = atomic.LoadInt64(&route.dropped)
+	}
+	dropped[DefaultRoute] = atomic.LoadInt64(&rs.defaultRoute.dropped)
+	return dropped
+}
+
+func (rs *routingSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
+	return rs.SendMetricContext(context.Background(), name, value, ts, source, tags)
+}
+
+func (rs *routingSender) SendMetricContext(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error {
+	return rs.send(Datum{Type: PointData, Name: name, Source: source, tags: tags}, func(sender Sender) error {
+		return sender.SendMetricContext(ctx, name, value, ts, source, tags)
+	})
+}
+
+func (rs *routingSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	return rs.SendDeltaCounterContext(context.Background(), name, value, source, tags)
+}
+
+func (rs *routingSender) SendDeltaCounterContext(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	return rs.send(Datum{Type: PointData, Name: name, Source: source, tags: tags}, func(sender Sender) error {
+		return sender.SendDeltaCounterContext(ctx, name, value, source, tags)
+	})
+}
+
+func (rs *routingSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return rs.SendDistributionContext(context.Background(), name, centroids, hgs, ts, source, tags)
+}
+
+func (rs *routingSender) SendDistributionContext(ctx context.Context, name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return rs.send(Datum{Type: HistogramData, Name: name, Source: source, tags: tags}, func(sender Sender) error {
+		return sender.SendDistributionContext(ctx, name, centroids, hgs, ts, source, tags)
+	})
+}
+
+func (rs *routingSender) SendSpan(name string, startMillis, durationMillis int64, source, traceID, spanID string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return rs.SendSpanContext(context.Background(), name, startMillis, durationMillis, source, traceID, spanID, parents, followsFrom, tags, spanLogs)
+}
+
+func (rs *routingSender) SendSpanContext(ctx context.Context, name string, startMillis, durationMillis int64, source, traceID, spanID string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return rs.send(Datum{Type: SpanData, Name: name, Source: source, spanTags: tags}, func(sender Sender) error {
+		return sender.SendSpanContext(ctx, name, startMillis, durationMillis, source, traceID, spanID, parents, followsFrom, tags, spanLogs)
+	})
+}
+
+func (rs *routingSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return rs.SendEventContext(context.Background(), name, startMillis, endMillis, source, tags, setters...)
+}
+
+func (rs *routingSender) SendEventContext(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return rs.send(Datum{Type: EventData, Name: name, Source: source, tags: tags}, func(sender Sender) error {
+		return sender.SendEventContext(ctx, name, startMillis, endMillis, source, tags, setters...)
+	})
+}
+
+// SendPoints sends each part of the batch going to the same route at once. The parts are
+// validated separately.
+func (rs *routingSender) SendPoints(ctx context.Context, points []Point) error {
+	return sendRouted(rs, points, func(p Point) Datum {
+		return Datum{Type: PointData, Name: p.Name, Source: p.Source, tags: p.Tags}
+	}, func(sender Sender, points []Point) error {
+		return sender.SendPoints(ctx, points)
+	})
+}
+
+func (rs *routingSender) SendDistributions(ctx context.Context, distributions []Distribution) error {
+	return sendRouted(rs, distributions, func(d Distribution) Datum {
+		return Datum{Type: HistogramData, Name: d.Name, Source: d.Source, tags: d.Tags}
+	}, func(sender Sender, distributions []Distribution) error {
+		return sender.SendDistributions(ctx, distributions)
+	})
+}
+
+func (rs *routingSender) SendSpans(ctx context.Context, spans []Span) error {
+	return sendRouted(rs, spans, func(s Span) Datum {
+		return Datum{Type: SpanData, Name: s.Name, Source: s.Source, spanTags: s.Tags}
+	}, func(sender Sender, spans []Span) error {
+		return sender.SendSpans(ctx, spans)
+	})
+}
+
+func (rs *routingSender) SendEvents(ctx context.Context, events []Event) error {
+	return sendRouted(rs, events, func(e Event) Datum {
+		return Datum{Type: EventData, Name: e.Name, Source: e.Source, tags: e.Tags}
+	}, func(sender Sender, events []Event) error {
+		return sender.SendEvents(ctx, events)
+	})
+}
+
+// sendRouted sends the items of a batch with send, in parts going to the same route.
+func sendRouted[T any](rs *routingSender, items []T, datumOf func(T) Datum, send func(Sender, []T) error) error {
+	var routes []*Route
+	parts := map[*Route]*batchPart[T]{}
+	for i, item := range items {
+		route := rs.route(datumOf(item))
+		part, ok := parts[route]
+		if !ok {
+			part = &batchPart[T]{}
+			parts[route] = part
+			routes = append(routes, route)
+		}
+		part.add(i, item)
+	}
+
+	var batchErr BatchError
+	for _, route := range routes {
+		part := parts[route]
+		if route.Sender == nil {
+			atomic.AddInt64(&route.dropped, int64(len(part.items)))
+			continue
+		}
+		dropped := part.addErrors(&batchErr, send(route.Sender, part.items), func(err error) error {
+			return destinationError(route.Sender, route.Name, err)
+		})
+		atomic.AddInt64(&route.dropped, int64(dropped))
+	}
+	return batchErr.get()
+}
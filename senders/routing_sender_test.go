@@ -0,0 +1,105 @@
+package senders
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockSender() *realSender {
+	return &realSender{
+		defaultSource:    "test",
+		pointHandler:     &mockHandler{},
+		histoHandler:     &mockHandler{},
+		spanHandler:      &mockHandler{},
+		spanLogHandler:   &mockHandler{},
+		eventHandler:     &mockHandler{},
+		internalRegistry: &mockRegistry{},
+	}
+}
+
+func linesOf(handler interface{}) []string {
+	return handler.(*mockHandler).Lines
+}
+
+func TestRoutingSender(t *testing.T) {
+	billing, tracing, fallback := newMockSender(), newMockSender(), newMockSender()
+	sender, err := NewRoutingSender(fallback,
+		Route{Name: "billing", Sender: billing, Matchers: []Matcher{NamePrefix("billing."), OfType(PointData)}},
+		Route{Name: "tracing", Sender: tracing, Matchers: []Matcher{OfType(SpanData)}},
+		Route{Name: "payments", Sender: billing, Matchers: []Matcher{TagRegexp("team", regexp.MustCompile("^pay"))}},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, sender.SendMetric("billing.invoices", 1, 0, "test", nil))
+	require.NoError(t, sender.SendMetric("requests", 2, 0, "test", map[string]string{"team": "payments"}))
+	require.NoError(t, sender.SendMetric("requests", 3, 0, "test", nil))
+	require.NoError(t, sender.SendSpan("billing.charge", 0, 1, "test", "7b3bf470-9456-11e8-9eb6-529269fb1459",
+		"0313bafe-9457-11e8-9eb6-529269fb1459", nil, nil, nil, nil))
+	require.NoError(t, sender.SendEvent("deploy", 0, 1, "test", nil))
+
+	assert.Equal(t, []string{
+		"\"billing.invoices\" 1 source=\"test\"\n",
+		"\"requests\" 2 source=\"test\" \"team\"=\"payments\"\n",
+	}, linesOf(billing.pointHandler))
+	assert.Len(t, linesOf(tracing.spanHandler), 1)
+	assert.Empty(t, linesOf(billing.spanHandler))
+	assert.Equal(t, []string{"\"requests\" 3 source=\"test\"\n"}, linesOf(fallback.pointHandler))
+	assert.Len(t, linesOf(fallback.eventHandler), 1)
+
+	// the senders of several routes are flushed and closed once.
+	assert.Len(t, sender.(*routingSender).senders, 3)
+	assert.Equal(t, map[string]int64{"billing": 0, "tracing": 0, "payments": 0, DefaultRoute: 0}, sender.Dropped())
+
+	sender, err = NewRoutingSender(billing, Route{Name: "billing", Sender: BestEffort(billing)})
+	require.NoError(t, err)
+	assert.Len(t, sender.(*routingSender).senders, 1)
+}
+
+func TestRoutingSender_Dropped(t *testing.T) {
+	billing := newMockSender()
+	billing.pointHandler.(*mockHandler).Error = errBufferFull
+	sender, err := NewRoutingSender(nil, Route{Name: "billing", Sender: billing, Matchers: []Matcher{NamePrefix("billing.")}})
+	require.NoError(t, err)
+
+	assert.EqualError(t, sender.SendMetric("billing.invoices", 1, 0, "test", nil), "billing: buffer full")
+	assert.NoError(t, sender.SendMetric("requests", 2, 0, "test", nil))
+	assert.NoError(t, sender.SendMetric("requests", 3, 0, "test", nil))
+	assert.Equal(t, map[string]int64{"billing": 1, DefaultRoute: 2}, sender.Dropped())
+}
+
+func TestRoutingSender_InvalidRoutes(t *testing.T) {
+	sender := newMockSender()
+	_, err := NewRoutingSender(sender, Route{Sender: sender})
+	assert.Error(t, err)
+	_, err = NewRoutingSender(sender, Route{Name: DefaultRoute, Sender: sender})
+	assert.Error(t, err)
+	_, err = NewRoutingSender(sender, Route{Name: "a", Sender: sender}, Route{Name: "a", Sender: sender})
+	assert.Error(t, err)
+	_, err = NewRoutingSender(sender, Route{Name: "a"})
+	assert.Error(t, err)
+}
+
+func TestRoutingSender_SendPoints(t *testing.T) {
+	billing, fallback := newMockSender(), newMockSender()
+	billing.pointHandler.(*mockHandler).Error = errBufferFull
+	sender, err := NewRoutingSender(fallback, Route{Name: "billing", Sender: billing, Matchers: []Matcher{NamePrefix("billing.")}})
+	require.NoError(t, err)
+
+	err = sender.SendPoints(context.Background(), []Point{
+		{Name: "requests", Value: 1},
+		{Name: "billing.invoices", Value: 2},
+		{Name: "requests", Value: 3},
+		{Name: "billing.invoices", Value: 4},
+	})
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []int{1, 3}, itemIndices(batchErr))
+	assert.EqualError(t, batchErr.Errors[0].Err, "billing: buffer full")
+	assert.Len(t, linesOf(billing.pointHandler), 2)
+	assert.Equal(t, []string{"\"requests\" 1 source=\"test\"\n", "\"requests\" 3 source=\"test\"\n"}, linesOf(fallback.pointHandler))
+	assert.Equal(t, map[string]int64{"billing": 2, DefaultRoute: 0}, sender.Dropped())
+}
@@ -0,0 +1,334 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wavefronthq/wavefront-sdk-go/event"
+	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+)
+
+// shardReplicas is the number of points of each sender on the hash ring. The more points,
+// the more evenly series are spread over the senders.
+const shardReplicas = 128
+
+// ShardedSender is a MultiSender spreading data over its senders, each time series,
+// identified by its name, source and tags, being always sent by the same sender.
+type ShardedSender interface {
+	MultiSender
+	// Add adds sender to the senders of the ShardedSender. The only series that move are
+	// the ones that are now sent by sender.
+	Add(sender Sender) error
+	// Remove removes the sender called name from the senders of the ShardedSender, and
+	// returns it for the caller to close once it sent the data it buffered. The only series
+	// that move are the ones that were sent by the removed sender.
+	Remove(name string) (Sender, error)
+}
+
+type shardedSender struct {
+	// serializes Add and Remove.
+	mtx    sync.Mutex
+	shards atomic.Pointer[shards]
+}
+
+// shards is an immutable consistent hash ring over senders, replaced as a whole when
+// senders are added or removed.
+type shards struct {
+	// flushes, closes and shuts down every sender.
+	*multiSender
+	names  []string
+	points []shardPoint
+}
+
+// shardPoint is a point of the hash ring, owning the series hashed between the
+// previous point and itself.
+type shardPoint struct {
+	hash   uint64
+	sender int
+}
+
+// NewShardedSender creates a ShardedSender spreading data over senders, with consistent hashing
+// on the identity of series: name, source and tags for metrics, delta counters and distributions,
+// name and source for events, and trace ID for spans, which keeps the spans of a trace together.
+// Senders are identified by their name, set with Named, or the URL they send data to for
+// the senders created with NewSender. Names must be unique.
+func NewShardedSender(senders ...Sender) (ShardedSender, error) {
+	s, err := newShards(senders)
+	if err != nil {
+		return nil, err
+	}
+	ss := &shardedSender{}
+	ss.shards.Store(s)
+	return ss, nil
+}
+
+func newShards(senders []Sender) (*shards, error) {
+	s := &shards{multiSender: &multiSender{senders: senders}}
+	for i, sender := range senders {
+		name := destinationName(sender)
+		if name == "" {
+			return nil, fmt.Errorf("sender %d of a sharded sender must be named", i)
+		}
+		for _, other := range s.names {
+			if name == other {
+				return nil, fmt.Errorf("duplicate sender '%s' in a sharded sender", name)
+			}
+		}
+		s.names = append(s.names, name)
+		for replica := 0; replica < shardReplicas; replica++ {
+			s.points = append(s.points, shardPoint{
+				hash:   mixHash(hashString(fnvOffset, name+"#"+strconv.Itoa(replica))),
+				sender: i,
+			})
+		}
+	}
+	sort.Slice(s.points, func(i, j int) bool {
+		return s.points[i].hash < s.points[j].hash
+	})
+	return s, nil
+}
+
+// sender returns the sender owning hash, or nil without senders.
+func (s *shards) sender(hash uint64) Sender {
+	if len(s.points) == 0 {
+		return nil
+	}
+	return s.senders[s.senderIndex(hash)]
+}
+
+// senderIndex returns the index of the sender owning hash, which requires senders.
+func (s *shards) senderIndex(hash uint64) int {
+	i := sort.Search(len(s.points), func(i int) bool {
+		return s.points[i].hash >= hash
+	})
+	if i == len(s.points) {
+		i = 0
+	}
+	return s.points[i].sender
+}
+
+func (ss *shardedSender) Add(sender Sender) error {
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+	current := ss.shards.Load()
+	senders := append(append([]Sender(nil), current.senders...), sender)
+	s, err := newShards(senders)
+	if err != nil {
+		return err
+	}
+	ss.shards.Store(s)
+	return nil
+}
+
+func (ss *shardedSender) Remove(name string) (Sender, error) {
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+	current := ss.shards.Load()
+	for i, other := range current.names {
+		if other != name {
+			continue
+		}
+		senders := append(append([]Sender(nil), current.senders[:i]...), current.senders[i+1:]...)
+		s, err := newShards(senders)
+		if err != nil {
+			return nil, err
+		}
+		ss.shards.Store(s)
+		return current.senders[i], nil
+	}
+	return nil, fmt.Errorf("no sender '%s' in the sharded sender", name)
+}
+
+// send sends data with the sender owning hash.
+func (ss *shardedSender) send(hash uint64, send func(Sender) error) error {
+	sender := ss.shards.Load().sender(hash)
+	if sender == nil {
+		return fmt.Errorf("no sender in the sharded sender")
+	}
+	return destinationError(sender, "", send(sender))
+}
+
+func (ss *shardedSender) private() {
+}
+
+func (ss *shardedSender) SendMetric(name string, value float64, ts int64, source string, tags map[string]string) error {
+	return ss.SendMetricContext(context.Background(), name, value, ts, source, tags)
+}
+
+func (ss *shardedSender) SendMetricContext(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error {
+	return ss.send(seriesHash(name, source, tags), func(sender Sender) error {
+		return sender.SendMetricContext(ctx, name, value, ts, source, tags)
+	})
+}
+
+func (ss *shardedSender) SendDeltaCounter(name string, value float64, source string, tags map[string]string) error {
+	return ss.SendDeltaCounterContext(context.Background(), name, value, source, tags)
+}
+
+func (ss *shardedSender) SendDeltaCounterContext(ctx context.Context, name string, value float64, source string, tags map[string]string) error {
+	return ss.send(seriesHash(name, source, tags), func(sender Sender) error {
+		return sender.SendDeltaCounterContext(ctx, name, value, source, tags)
+	})
+}
+
+func (ss *shardedSender) SendDistribution(name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return ss.SendDistributionContext(context.Background(), name, centroids, hgs, ts, source, tags)
+}
+
+func (ss *shardedSender) SendDistributionContext(ctx context.Context, name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error {
+	return ss.send(seriesHash(name, source, tags), func(sender Sender) error {
+		return sender.SendDistributionContext(ctx, name, centroids, hgs, ts, source, tags)
+	})
+}
+
+func (ss *shardedSender) SendSpan(name string, startMillis, durationMillis int64, source, traceID, spanID string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return ss.SendSpanContext(context.Background(), name, startMillis, durationMillis, source, traceID, spanID, parents, followsFrom, tags, spanLogs)
+}
+
+func (ss *shardedSender) SendSpanContext(ctx context.Context, name string, startMillis, durationMillis int64, source, traceID, spanID string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error {
+	return ss.send(mixHash(hashString(fnvOffset, traceID)), func(sender Sender) error {
+		return sender.SendSpanContext(ctx, name, startMillis, durationMillis, source, traceID, spanID, parents, followsFrom, tags, spanLogs)
+	})
+}
+
+func (ss *shardedSender) SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return ss.SendEventContext(context.Background(), name, startMillis, endMillis, source, tags, setters...)
+}
+
+func (ss *shardedSender) SendEventContext(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error {
+	return ss.send(seriesHash(name, source, nil), func(sender Sender) error {
+		return sender.SendEventContext(ctx, name, startMillis, endMillis, source, tags, setters...)
+	})
+}
+
+// SendPoints sends each part of the batch going to the same sender at once. The parts are
+// validated separately.
+func (ss *shardedSender) SendPoints(ctx context.Context, points []Point) error {
+	return sendSharded(ss, points, func(p Point) uint64 {
+		return seriesHash(p.Name, p.Source, p.Tags)
+	}, func(sender Sender, points []Point) error {
+		return sender.SendPoints(ctx, points)
+	})
+}
+
+func (ss *shardedSender) SendDistributions(ctx context.Context, distributions []Distribution) error {
+	return sendSharded(ss, distributions, func(d Distribution) uint64 {
+		return seriesHash(d.Name, d.Source, d.Tags)
+	}, func(sender Sender, distributions []Distribution) error {
+		return sender.SendDistributions(ctx, distributions)
+	})
+}
+
+func (ss *shardedSender) SendSpans(ctx context.Context, spans []Span) error {
+	return sendSharded(ss, spans, func(s Span) uint64 {
+		return mixHash(hashString(fnvOffset, s.TraceID))
+	}, func(sender Sender, spans []Span) error {
+		return sender.SendSpans(ctx, spans)
+	})
+}
+
+func (ss *shardedSender) SendEvents(ctx context.Context, events []Event) error {
+	return sendSharded(ss, events, func(e Event) uint64 {
+		return seriesHash(e.Name, e.Source, nil)
+	}, func(sender Sender, events []Event) error {
+		return sender.SendEvents(ctx, events)
+	})
+}
+
+// sendSharded sends the items of a batch with send, in parts going to the same sender.
+func sendSharded[T any](ss *shardedSender, items []T, hashOf func(T) uint64, send func(Sender, []T) error) error {
+	s := ss.shards.Load()
+	if len(s.senders) == 0 {
+		if len(items) == 0 {
+			return nil
+		}
+		return fmt.Errorf("no sender in the sharded sender")
+	}
+	parts := make([]*batchPart[T], len(s.senders))
+	for i, item := range items {
+		sender := s.senderIndex(hashOf(item))
+		if parts[sender] == nil {
+			parts[sender] = &batchPart[T]{}
+		}
+		parts[sender].add(i, item)
+	}
+
+	var batchErr BatchError
+	for i, part := range parts {
+		if part == nil {
+			continue
+		}
+		sender := s.senders[i]
+		part.addErrors(&batchErr, send(sender, part.items), func(err error) error {
+			return destinationError(sender, "", err)
+		})
+	}
+	return batchErr.get()
+}
+
+func (ss *shardedSender) Flush() error {
+	return ss.shards.Load().Flush()
+}
+
+func (ss *shardedSender) FlushContext(ctx context.Context) error {
+	return ss.shards.Load().FlushContext(ctx)
+}
+
+func (ss *shardedSender) GetFailureCount() int64 {
+	return ss.shards.Load().GetFailureCount()
+}
+
+func (ss *shardedSender) Start() {
+	ss.shards.Load().Start()
+}
+
+func (ss *shardedSender) Close() {
+	ss.shards.Load().Close()
+}
+
+func (ss *shardedSender) CloseContext(ctx context.Context) error {
+	return ss.shards.Load().CloseContext(ctx)
+}
+
+func (ss *shardedSender) Shutdown(ctx context.Context) (ShutdownResult, error) {
+	return ss.shards.Load().Shutdown(ctx)
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// hashString continues the 64-bit FNV-1a hash h with s, followed by a zero byte for
+// consecutive strings not to run together.
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return h * fnvPrime
+}
+
+// mixHash spreads the bits of h, for the hashes of similar series to land far apart on the ring.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// seriesHash hashes the identity of a series. The hashes of the tags are summed, which does not
+// depend on the order they are iterated in, and saves sorting them.
+func seriesHash(name, source string, tags map[string]string) uint64 {
+	h := hashString(hashString(fnvOffset, name), source)
+	for k, v := range tags {
+		h += mixHash(hashString(hashString(fnvOffset, k), v))
+	}
+	return mixHash(h)
+}
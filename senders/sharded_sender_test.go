@@ -0,0 +1,142 @@
+package senders
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNamedMockSenders(names ...string) []Sender {
+	var senders []Sender
+	for _, name := range names {
+		senders = append(senders, Named(name, newMockSender()))
+	}
+	return senders
+}
+
+// owners returns the name of the sender of each of n series.
+func owners(s *shards, n int) []string {
+	owners := make([]string, n)
+	for i := range owners {
+		hash := seriesHash("my.metric", "host-"+strconv.Itoa(i), map[string]string{"env": "prod"})
+		owners[i] = destinationName(s.sender(hash))
+	}
+	return owners
+}
+
+func TestSeriesHash(t *testing.T) {
+	tags := map[string]string{"env": "prod", "region": "us-west", "team": "billing"}
+	assert.Equal(t, seriesHash("my.metric", "host", tags),
+		seriesHash("my.metric", "host", map[string]string{"team": "billing", "region": "us-west", "env": "prod"}))
+	assert.NotEqual(t, seriesHash("my.metric", "host", tags), seriesHash("my.metric", "host", nil))
+	assert.NotEqual(t, seriesHash("my.metric", "host", nil), seriesHash("my.metrichost", "", nil))
+	assert.NotEqual(t, seriesHash("my.metric", "host", map[string]string{"ab": "c"}),
+		seriesHash("my.metric", "host", map[string]string{"a": "bc"}))
+}
+
+func TestShardedSender_SpreadsSeries(t *testing.T) {
+	sender, err := NewShardedSender(newNamedMockSenders("a", "b", "c", "d")...)
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+	for _, owner := range owners(sender.(*shardedSender).shards.Load(), 10000) {
+		counts[owner]++
+	}
+	assert.Len(t, counts, 4)
+	for name, count := range counts {
+		assert.InDelta(t, 2500, count, 500, name)
+	}
+}
+
+func TestShardedSender_RebalancesMinimally(t *testing.T) {
+	sender, err := NewShardedSender(newNamedMockSenders("a", "b", "c")...)
+	require.NoError(t, err)
+	ss := sender.(*shardedSender)
+	before := owners(ss.shards.Load(), 10000)
+
+	require.NoError(t, sender.Add(newNamedMockSenders("d")[0]))
+	afterAdd := owners(ss.shards.Load(), 10000)
+	moved := 0
+	for i := range before {
+		if before[i] != afterAdd[i] {
+			assert.Equal(t, "d", afterAdd[i])
+			moved++
+		}
+	}
+	assert.InDelta(t, 2500, moved, 500)
+
+	removed, err := sender.Remove("b")
+	require.NoError(t, err)
+	assert.Equal(t, "b", destinationName(removed))
+	afterRemove := owners(ss.shards.Load(), 10000)
+	for i := range afterAdd {
+		if afterAdd[i] != "b" {
+			assert.Equal(t, afterAdd[i], afterRemove[i])
+		}
+		assert.NotEqual(t, "b", afterRemove[i])
+	}
+
+	_, err = sender.Remove("b")
+	assert.Error(t, err)
+	assert.Error(t, sender.Add(newNamedMockSenders("a")[0]))
+}
+
+func TestShardedSender(t *testing.T) {
+	a, b := newMockSender(), newMockSender()
+	sender, err := NewShardedSender(Named("a", a), Named("b", b))
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 3; j++ {
+			require.NoError(t, sender.SendMetric("my.metric", float64(j), 0, "host-"+strconv.Itoa(i), nil))
+		}
+	}
+	assert.Len(t, append(linesOf(a.pointHandler), linesOf(b.pointHandler)...), 30)
+	assert.Zero(t, len(linesOf(a.pointHandler))%3, "the points of a series are sent by the same sender")
+	assert.NotEmpty(t, linesOf(a.pointHandler))
+	assert.NotEmpty(t, linesOf(b.pointHandler))
+	require.NoError(t, sender.Flush())
+
+	_, err = NewShardedSender(newMockSender())
+	assert.Error(t, err)
+	_, err = NewShardedSender(newNamedMockSenders("a", "a")...)
+	assert.Error(t, err)
+	empty, err := NewShardedSender()
+	require.NoError(t, err)
+	assert.Error(t, empty.SendMetric("my.metric", 1, 0, "host", nil))
+}
+
+func TestShardedSender_SendPoints(t *testing.T) {
+	a, b := newMockSender(), newMockSender()
+	sender, err := NewShardedSender(Named("a", a), Named("b", b))
+	require.NoError(t, err)
+
+	var points []Point
+	for i := 0; i < 20; i++ {
+		points = append(points, Point{Name: "my.metric", Value: float64(i), Source: "host-" + strconv.Itoa(i%5)})
+	}
+	require.NoError(t, sender.SendPoints(context.Background(), points))
+	assert.Len(t, append(linesOf(a.pointHandler), linesOf(b.pointHandler)...), 20)
+	for _, p := range points {
+		var single *realSender
+		if destinationName(sender.(*shardedSender).shards.Load().sender(seriesHash(p.Name, p.Source, nil))) == "a" {
+			single = a
+		} else {
+			single = b
+		}
+		assert.Contains(t, linesOf(single.pointHandler), "\"my.metric\" "+strconv.Itoa(int(p.Value))+" source=\""+p.Source+"\"\n")
+	}
+
+	b.pointHandler.(*mockHandler).Error = errBufferFull
+	err = sender.SendPoints(context.Background(), points)
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.NotEmpty(t, batchErr.Errors)
+	assert.Less(t, len(batchErr.Errors), 20)
+	var destinationErr *DestinationError
+	require.ErrorAs(t, err, &destinationErr)
+	assert.Equal(t, "b", destinationErr.Destination)
+}
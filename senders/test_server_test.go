@@ -3,13 +3,17 @@ package senders
 import (
 	"bufio"
 	"compress/gzip"
+	"compress/zlib"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 func startTestServer(useTLS bool) *testServer {
@@ -33,6 +37,10 @@ type testServer struct {
 	httpServer  *httptest.Server
 	URL         string
 	RequestURLs []string
+	Encodings   []string
+
+	// delay before the report endpoint responds, in nanoseconds.
+	delay atomic.Int64
 }
 
 func (s *testServer) TLSConfig() *tls.Config {
@@ -49,7 +57,13 @@ func (s *testServer) ReportEndpoint(writer http.ResponseWriter, request *http.Re
 		writer.WriteHeader(500)
 		return
 	}
+	select {
+	case <-time.After(time.Duration(s.delay.Load())):
+	case <-request.Context().Done():
+		return
+	}
 	s.MetricLines = append(s.MetricLines, newLines...)
+	s.Encodings = append(s.Encodings, request.Header.Get("Content-Encoding"))
 	s.AuthHeaders = append(s.AuthHeaders, request.Header.Get("Authorization"))
 	s.RequestURLs = append(s.RequestURLs, request.URL.String())
 	writer.WriteHeader(200)
@@ -72,14 +86,22 @@ func decodeLines(request *http.Request) ([]string, error) {
 	var metricLines []string
 	var bodyReader io.Reader
 	defer request.Body.Close()
-	if request.Header.Get("Content-Encoding") == "gzip" {
+	switch request.Header.Get("Content-Encoding") {
+	case "gzip":
 		r, err := gzip.NewReader(request.Body)
 		if err != nil {
 			return metricLines, err
 		}
 		defer r.Close()
 		bodyReader = r
-	} else {
+	case "deflate":
+		r, err := zlib.NewReader(request.Body)
+		if err != nil {
+			return metricLines, err
+		}
+		defer r.Close()
+		bodyReader = r
+	default:
 		bodyReader = request.Body
 	}
 
@@ -107,3 +129,51 @@ func (s *testServer) hasReceivedLine(lineSubstring string) bool {
 	}
 	return internalMetricFound
 }
+
+// startTestSocketServer accepts plaintext connections on a local port and records every line received.
+func startTestSocketServer(network, address string) (*testSocketServer, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	s := &testSocketServer{listener: listener, received: make(chan string, 1000)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					s.received <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return s, nil
+}
+
+type testSocketServer struct {
+	listener net.Listener
+	received chan string
+}
+
+func (s *testSocketServer) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// nextLine waits for the next line received by the server.
+func (s *testSocketServer) nextLine() string {
+	select {
+	case line := <-s.received:
+		return line
+	case <-time.After(5 * time.Second):
+		return ""
+	}
+}
+
+func (s *testSocketServer) Close() {
+	_ = s.listener.Close()
+}
@@ -1,6 +1,8 @@
 package senders
 
 import (
+	"context"
+
 	"github.com/wavefronthq/wavefront-sdk-go/event"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
 )
@@ -41,6 +43,130 @@ type EventSender interface {
 	SendEvent(name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error
 }
 
+// ContextSender Interface for sending data to Wavefront with a context.Context bounding each call.
+// A send only waits when the buffer of its data type is full and the Block overflow policy is set.
+// A flush, or close, that gives up because its context is done keeps the unreported data buffered,
+// or persisted with a persistent buffer, and returns an error wrapping the error of the context.
+type ContextSender interface {
+	SendMetricContext(ctx context.Context, name string, value float64, ts int64, source string, tags map[string]string) error
+	SendDeltaCounterContext(ctx context.Context, name string, value float64, source string, tags map[string]string) error
+	SendDistributionContext(ctx context.Context, name string, centroids []histogram.Centroid, hgs map[histogram.Granularity]bool, ts int64, source string, tags map[string]string) error
+	SendSpanContext(ctx context.Context, name string, startMillis, durationMillis int64, source, traceID, spanID string, parents, followsFrom []string, tags []SpanTag, spanLogs []SpanLog) error
+	SendEventContext(ctx context.Context, name string, startMillis, endMillis int64, source string, tags map[string]string, setters ...event.Option) error
+
+	// FlushContext flushes a batch of buffered data of every type.
+	FlushContext(ctx context.Context) error
+
+	// CloseContext flushes all buffered data and stops the sender.
+	CloseContext(ctx context.Context) error
+
+	// Shutdown flushes all buffered data, of every type in parallel, and stops the sender.
+	// Flushing stops once ctx is done or a report fails, and the data left unreported is persisted
+	// with a persistent buffer, or lost. The result tells how much data was delivered, persisted and lost.
+	Shutdown(ctx context.Context) (ShutdownResult, error)
+}
+
+// BatchSender Interface for sending batches of data to Wavefront, each batch being formatted and
+// buffered at once. Every item of a batch is validated before any is sent: when an item is invalid,
+// none are sent and a *BatchError tells which items are invalid. Otherwise, the items that could
+// not be buffered are returned as a *BatchError, the other ones being sent.
+type BatchSender interface {
+	// SendPoints sends metric points, with optional timestamps and tags.
+	SendPoints(ctx context.Context, points []Point) error
+	// SendDistributions sends distributions, with optional timestamps and tags.
+	SendDistributions(ctx context.Context, distributions []Distribution) error
+	// SendSpans sends tracing spans, along with their span logs.
+	SendSpans(ctx context.Context, spans []Span) error
+	// SendEvents sends events, with optional tags.
+	SendEvents(ctx context.Context, events []Event) error
+}
+
+// Point is a metric point, as sent by SendMetric.
+type Point struct {
+	Name  string
+	Value float64
+	// Timestamp is in seconds or milliseconds since the epoch, the time it is received when 0.
+	Timestamp int64
+	Source    string
+	Tags      map[string]string
+}
+
+// Distribution is a distribution, as sent by SendDistribution.
+type Distribution struct {
+	Name          string
+	Centroids     []histogram.Centroid
+	Granularities map[histogram.Granularity]bool
+	Timestamp     int64
+	Source        string
+	Tags          map[string]string
+}
+
+// Span is a tracing span, as sent by SendSpan.
+type Span struct {
+	Name           string
+	StartMillis    int64
+	DurationMillis int64
+	Source         string
+	TraceID        string
+	SpanID         string
+	Parents        []string
+	FollowsFrom    []string
+	Tags           []SpanTag
+	SpanLogs       []SpanLog
+}
+
+// Event is an event, as sent by SendEvent.
+type Event struct {
+	Name        string
+	StartMillis int64
+	EndMillis   int64
+	Source      string
+	Tags        map[string]string
+	Options     []event.Option
+}
+
+// DrainStats counts what happened to the data of a type buffered by a Sender when it was shut down.
+type DrainStats struct {
+	// Delivered is the number of items reported while shutting down.
+	Delivered int
+	// Persisted is the number of items left unreported that were written to the persistent
+	// buffer, to be reported by the next sender started on it.
+	Persisted int
+	// Lost is the number of items left unreported that were dropped.
+	Lost int
+}
+
+func (s *DrainStats) add(other DrainStats) {
+	s.Delivered += other.Delivered
+	s.Persisted += other.Persisted
+	s.Lost += other.Lost
+}
+
+// ShutdownResult reports what happened to the data buffered by a Sender when it was shut down.
+type ShutdownResult struct {
+	DataTypes map[DataType]DrainStats
+}
+
+// Total sums the stats of every data type.
+func (r ShutdownResult) Total() DrainStats {
+	var total DrainStats
+	for _, stats := range r.DataTypes {
+		total.add(stats)
+	}
+	return total
+}
+
+func (r *ShutdownResult) merge(other ShutdownResult) {
+	if r.DataTypes == nil {
+		r.DataTypes = make(map[DataType]DrainStats, len(other.DataTypes))
+	}
+	for dataType, stats := range other.DataTypes {
+		merged := r.DataTypes[dataType]
+		merged.add(stats)
+		r.DataTypes[dataType] = merged
+	}
+}
+
 type SpanTag struct {
 	Key   string
 	Value string
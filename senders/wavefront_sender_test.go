@@ -1,11 +1,14 @@
 package senders
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wavefronthq/wavefront-sdk-go/histogram"
+	"github.com/wavefronthq/wavefront-sdk-go/internal"
 	"github.com/wavefronthq/wavefront-sdk-go/internal/sdkmetrics"
 )
 
@@ -257,6 +260,84 @@ func TestWavefrontSender_SendSpan_SpanLogs(t *testing.T) {
 	)
 }
 
+func TestWavefrontSender_SendPoints(t *testing.T) {
+	sender := newMockSender()
+	pointHandler := sender.pointHandler.(*mockHandler)
+	tracker := sender.internalRegistry.PointsTracker().(*simpleTracker)
+
+	assert.NoError(t, sender.SendPoints(context.Background(), []Point{
+		{Name: "foo", Value: 20},
+		{Name: "bar", Value: 21, Timestamp: 1700000000, Source: "host", Tags: map[string]string{"env": "prod"}},
+	}))
+	assert.Equal(t, []string{
+		"\"foo\" 20 source=\"test\"\n",
+		"\"bar\" 21 1700000000 source=\"host\" \"env\"=\"prod\"\n",
+	}, pointHandler.Lines)
+	assert.Equal(t, 2, tracker.valid)
+	pointHandler.Reset()
+
+	err := sender.SendPoints(context.Background(), []Point{{Name: "foo", Value: 20}, {Value: 21}, {Name: "bar"}, {}})
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []int{1, 3}, itemIndices(batchErr))
+	assert.Empty(t, pointHandler.Lines, "nothing is sent when an item is invalid")
+	assert.Equal(t, 2, tracker.invalid)
+
+	pointHandler.Error = fmt.Errorf("fake error")
+	err = sender.SendPoints(context.Background(), []Point{{Name: "foo", Value: 20}, {Name: "bar", Value: 21}})
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []int{0, 1}, itemIndices(batchErr))
+	assert.ErrorIs(t, err, pointHandler.Error)
+	assert.Equal(t, 2, tracker.dropped)
+
+	assert.NoError(t, sender.SendPoints(context.Background(), nil))
+}
+
+func TestWavefrontSender_SendSpans(t *testing.T) {
+	sender := newMockSender()
+	spanHandler := sender.spanHandler.(*mockHandler)
+	spanLogHandler := sender.spanLogHandler.(*mockHandler)
+	traceID := "28e09666-9610-4690-a908-5298d95551ad"
+	spans := []Span{
+		{Name: "foo", StartMillis: 200, DurationMillis: 2000, TraceID: traceID, SpanID: "28b0ad93-58f5-4efe-a68b-7b7a84c8ace8"},
+		{
+			Name: "bar", StartMillis: 300, DurationMillis: 1000, TraceID: traceID, SpanID: "5b4ba4fa-ed5e-4b5a-9e9c-e33c9b8fe0bd",
+			Parents:  []string{"28b0ad93-58f5-4efe-a68b-7b7a84c8ace8"},
+			Tags:     []SpanTag{{Key: "env", Value: "prod"}},
+			SpanLogs: []SpanLog{{Timestamp: 10_000, Fields: map[string]string{"type": "birch"}}},
+		},
+	}
+
+	assert.NoError(t, sender.SendSpans(context.Background(), spans))
+	assert.Len(t, spanHandler.Lines, 2)
+	require.Len(t, spanLogHandler.Lines, 1)
+	assert.Contains(t, spanLogHandler.Lines[0], "\"spanId\":\"5b4ba4fa-ed5e-4b5a-9e9c-e33c9b8fe0bd\"")
+	spanHandler.Reset()
+	spanLogHandler.Reset()
+
+	spanLogHandler.Error = fmt.Errorf("fake error")
+	err := sender.SendSpans(context.Background(), spans)
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []int{1}, itemIndices(batchErr))
+	assert.Len(t, spanHandler.Lines, 2)
+
+	spanLogHandler.Reset()
+	spanHandler.Error = fmt.Errorf("fake error")
+	err = sender.SendSpans(context.Background(), spans)
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []int{0, 1}, itemIndices(batchErr))
+	assert.Empty(t, spanLogHandler.Lines, "the span logs of dropped spans are dropped")
+}
+
+func itemIndices(batchErr *BatchError) []int {
+	var indices []int
+	for _, itemErr := range batchErr.Errors {
+		indices = append(indices, itemErr.Index)
+	}
+	return indices
+}
+
 func TestWavefrontSender_SendEventWithProxyFalse(t *testing.T) {
 	registry := &mockRegistry{}
 	pointHandler := &mockHandler{}
@@ -331,17 +412,48 @@ func (m *mockHandler) HandleLine(line string) error {
 	return m.Error
 }
 
+func (m *mockHandler) HandleLineContext(ctx context.Context, line string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.HandleLine(line)
+}
+
+func (m *mockHandler) HandleLines(ctx context.Context, lines []string) []error {
+	var errs []error
+	for i, line := range lines {
+		if err := m.HandleLineContext(ctx, line); err != nil {
+			if errs == nil {
+				errs = make([]error, len(lines))
+			}
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
 func (m *mockHandler) Start() {
 }
 
 func (m *mockHandler) Stop() {
 }
 
+func (m *mockHandler) Drain(context.Context) internal.DrainResult {
+	return internal.DrainResult{Delivered: len(m.Lines), Err: m.Error}
+}
+
 func (m *mockHandler) Flush() error {
 	return m.Error
 }
 
-func (m *mockHandler) FlushWithThrottling() error {
+func (m *mockHandler) FlushContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Flush()
+}
+
+func (m *mockHandler) FlushWithThrottling(context.Context) error {
 	return m.Flush()
 }
 
@@ -358,6 +470,10 @@ type simpleTracker struct {
 	valid   int
 	invalid int
 	dropped int
+	blocked int
+	evicted int
+	spilled int
+	limited int
 }
 
 func (s *simpleTracker) IncValid() {
@@ -372,6 +488,22 @@ func (s *simpleTracker) IncDropped() {
 	s.dropped++
 }
 
+func (s *simpleTracker) IncBlocked() {
+	s.blocked++
+}
+
+func (s *simpleTracker) IncEvicted() {
+	s.evicted++
+}
+
+func (s *simpleTracker) IncSpilled() {
+	s.spilled++
+}
+
+func (s *simpleTracker) IncRateLimited() {
+	s.limited++
+}
+
 type mockRegistry struct {
 	pointsTracker     *simpleTracker
 	histogramsTracker *simpleTracker